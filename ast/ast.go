@@ -85,6 +85,15 @@ func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
 func (i *IntegerLiteral) String() string       { return i.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode()      {}
+func (f *FloatLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteral) String() string       { return f.Token.Literal }
+
 type ReturnStatement struct {
 	Token       token.Token
 	ReturnValue Expression
@@ -103,6 +112,49 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+type ThrowStatement struct {
+	Token token.Token // the throw token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// ImportStatement binds the module loaded from Path, to Alias if given
+// or else to the name of the module, see ModuleName.
+type ImportStatement struct {
+	Token token.Token // the import token
+	Alias *Identifier // nil without a from clause
+	Path  *StringLiteral
+}
+
+func (is *ImportStatement) statementNode()       {}
+func (is *ImportStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImportStatement) String() string {
+	if is.Alias != nil {
+		return is.TokenLiteral() + " " + is.Alias.String() + " from \"" + is.Path.Value + "\";"
+	}
+	return is.TokenLiteral() + " \"" + is.Path.Value + "\";"
+}
+
+// Name returns the name the module is bound to.
+func (is *ImportStatement) Name() string {
+	if is.Alias != nil {
+		return is.Alias.Value
+	}
+	return ModuleName(is.Path.Value)
+}
+
+// ModuleName returns the default name of the module imported from path:
+// its base name without the .mk extension. Import paths always use
+// slashes.
+func ModuleName(path string) string {
+	return strings.TrimSuffix(path[strings.LastIndex(path, "/")+1:], ".mk")
+}
+
 type ExpressionStatement struct {
 	Token      token.Token
 	Expression Expression
@@ -177,9 +229,39 @@ func (ie *IfExpression) String() string {
 	return buf.String()
 }
 
+// TryExpression has a CatchBody, a FinallyBody or both.
+type TryExpression struct {
+	Token       token.Token // the try token
+	Body        *BlockStatement
+	CatchParam  *Identifier
+	CatchBody   *BlockStatement
+	FinallyBody *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) String() string {
+	var buf strings.Builder
+	buf.WriteString("try ")
+	buf.WriteString(te.Body.String())
+
+	if te.CatchBody != nil {
+		buf.WriteString(" catch (" + te.CatchParam.String() + ") ")
+		buf.WriteString(te.CatchBody.String())
+	}
+
+	if te.FinallyBody != nil {
+		buf.WriteString(" finally ")
+		buf.WriteString(te.FinallyBody.String())
+	}
+
+	return buf.String()
+}
+
 type BlockStatement struct {
-	Token      token.Token
+	Token      token.Token // the { token
 	Statements []Statement
+	EndToken   token.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
@@ -195,7 +277,10 @@ func (bs *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token              token.Token
+	Name               string // the name of the let statement binding it, if any
 	FunctionParameters []*Identifier
+	Defaults           []Expression // the default value of each parameter, nil if it has none
+	Rest               *Identifier  // the ...rest parameter, nil if there is none
 	FunctionBody       *BlockStatement
 }
 
@@ -204,23 +289,44 @@ func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
 func (fl *FunctionLiteral) String() string {
 	var buf strings.Builder
 	buf.WriteString("fn(")
-
-	params := []string{}
-	for _, p := range fl.FunctionParameters {
-		params = append(params, p.TokenLiteral())
-	}
-	buf.WriteString(strings.Join(params, ", "))
-
+	buf.WriteString(ParameterList(fl.FunctionParameters, fl.Defaults, fl.Rest))
 	buf.WriteString(") {")
 	buf.WriteString(fl.FunctionBody.String())
 	buf.WriteString("}")
 	return buf.String()
 }
 
+// Default returns the default value of the parameter i, or nil.
+func (fl *FunctionLiteral) Default(i int) Expression {
+	if i < len(fl.Defaults) {
+		return fl.Defaults[i]
+	}
+	return nil
+}
+
+// ParameterList returns the parameters of a function as written between
+// its parentheses, such as "a, b = 2, ...rest". defaults is either nil or
+// parallel to params.
+func ParameterList(params []*Identifier, defaults []Expression, rest *Identifier) string {
+	list := []string{}
+	for i, p := range params {
+		if i < len(defaults) && defaults[i] != nil {
+			list = append(list, p.Value+" = "+defaults[i].String())
+		} else {
+			list = append(list, p.Value)
+		}
+	}
+	if rest != nil {
+		list = append(list, "..."+rest.Value)
+	}
+	return strings.Join(list, ", ")
+}
+
 type CallExpression struct {
 	Token     token.Token
 	Function  Expression // function expression or function name (identifier)
 	Arguments []Expression
+	Tail      bool // whether its value is the result of the enclosing function, see MarkTailCalls
 }
 
 func (ce *CallExpression) expressionNode()      {}
@@ -240,3 +346,91 @@ func (ce *CallExpression) String() string {
 
 	return buf.String()
 }
+
+// SpreadExpression is an argument ...xs passing the elements of an array
+// as separate arguments.
+type SpreadExpression struct {
+	Token token.Token // the ... token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
+// NamedArgument is an argument name: value passed to the parameter name.
+type NamedArgument struct {
+	Token token.Token // the : token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) String() string       { return sl.Token.Literal }
+
+type ArrayLiteral struct {
+	Token    token.Token // the [ token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) String() string {
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type IndexExpression struct {
+	Token token.Token // the [ token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) String() string {
+	return "(" + ie.Left.String() + "[" + ie.Index.String() + "])"
+}
+
+type MemberExpression struct {
+	Token    token.Token // the . token
+	Left     Expression
+	Property *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) String() string {
+	return "(" + me.Left.String() + "." + me.Property.String() + ")"
+}
+
+type HashLiteral struct {
+	Token token.Token // the { token
+	Keys  []Expression
+	Pairs map[Expression]Expression
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) String() string {
+	pairs := []string{}
+	for _, key := range hl.Keys {
+		pairs = append(pairs, key.String()+": "+hl.Pairs[key].String())
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
@@ -0,0 +1,56 @@
+package ast
+
+import "inter/token"
+
+// StartToken returns the first token of node, which carries its position.
+func StartToken(node Node) token.Token {
+	switch n := node.(type) {
+	case *LetStatement:
+		return n.Token
+	case *ReturnStatement:
+		return n.Token
+	case *ThrowStatement:
+		return n.Token
+	case *ImportStatement:
+		return n.Token
+	case *ExpressionStatement:
+		return n.Token
+	case *BlockStatement:
+		return n.Token
+	case *Identifier:
+		return n.Token
+	case *IntegerLiteral:
+		return n.Token
+	case *FloatLiteral:
+		return n.Token
+	case *BooleanLiteral:
+		return n.Token
+	case *StringLiteral:
+		return n.Token
+	case *PrefixExpression:
+		return n.Token
+	case *InfixExpression:
+		return StartToken(n.Left)
+	case *IfExpression:
+		return n.Token
+	case *TryExpression:
+		return n.Token
+	case *FunctionLiteral:
+		return n.Token
+	case *CallExpression:
+		return StartToken(n.Function)
+	case *SpreadExpression:
+		return n.Token
+	case *NamedArgument:
+		return n.Name.Token
+	case *ArrayLiteral:
+		return n.Token
+	case *IndexExpression:
+		return StartToken(n.Left)
+	case *MemberExpression:
+		return StartToken(n.Left)
+	case *HashLiteral:
+		return n.Token
+	}
+	return token.Token{}
+}
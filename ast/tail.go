@@ -0,0 +1,43 @@
+package ast
+
+// MarkTailCalls sets Tail on the calls in tail position in the body of a
+// function: calls whose value is returned by the function, either as the
+// value of its last statement or with a return statement. The value of an
+// if expression in tail position is the value of its arms.
+//
+// Calls in try expressions are not in tail position, since the function
+// still has to catch their errors or run the finally block after them.
+// Function literals in body are left alone, they are marked on their own.
+func MarkTailCalls(body *BlockStatement) {
+	markTailBlock(body, true)
+}
+
+func markTailBlock(block *BlockStatement, tail bool) {
+	if block == nil {
+		return
+	}
+	for i, st := range block.Statements {
+		markTailStatement(st, tail && i == len(block.Statements)-1)
+	}
+}
+
+func markTailStatement(st Statement, tail bool) {
+	switch st := st.(type) {
+	case *ReturnStatement:
+		// Wherever it is, a return statement reached through blocks and if
+		// expressions returns from the function.
+		markTailExpression(st.ReturnValue, true)
+	case *ExpressionStatement:
+		markTailExpression(st.Expression, tail)
+	}
+}
+
+func markTailExpression(exp Expression, tail bool) {
+	switch exp := exp.(type) {
+	case *CallExpression:
+		exp.Tail = tail
+	case *IfExpression:
+		markTailBlock(exp.Body, tail)
+		markTailBlock(exp.ElseBody, tail)
+	}
+}
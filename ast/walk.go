@@ -0,0 +1,90 @@
+package ast
+
+// Inspect traverses the tree rooted at node in depth-first order, calling f
+// for each node. Children are visited only when f returns true.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, st := range n.Statements {
+			Inspect(st, f)
+		}
+	case *LetStatement:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *ReturnStatement:
+		Inspect(n.ReturnValue, f)
+	case *ThrowStatement:
+		Inspect(n.Value, f)
+	case *ImportStatement:
+		if n.Alias != nil {
+			Inspect(n.Alias, f)
+		}
+		Inspect(n.Path, f)
+	case *ExpressionStatement:
+		Inspect(n.Expression, f)
+	case *BlockStatement:
+		for _, st := range n.Statements {
+			Inspect(st, f)
+		}
+	case *PrefixExpression:
+		Inspect(n.Right, f)
+	case *InfixExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *IfExpression:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+		if n.ElseBody != nil {
+			Inspect(n.ElseBody, f)
+		}
+	case *TryExpression:
+		Inspect(n.Body, f)
+		if n.CatchBody != nil {
+			Inspect(n.CatchParam, f)
+			Inspect(n.CatchBody, f)
+		}
+		if n.FinallyBody != nil {
+			Inspect(n.FinallyBody, f)
+		}
+	case *FunctionLiteral:
+		for i, p := range n.FunctionParameters {
+			Inspect(p, f)
+			if def := n.Default(i); def != nil {
+				Inspect(def, f)
+			}
+		}
+		if n.Rest != nil {
+			Inspect(n.Rest, f)
+		}
+		Inspect(n.FunctionBody, f)
+	case *CallExpression:
+		Inspect(n.Function, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	case *SpreadExpression:
+		Inspect(n.Value, f)
+	case *NamedArgument:
+		Inspect(n.Name, f)
+		Inspect(n.Value, f)
+	case *ArrayLiteral:
+		for _, el := range n.Elements {
+			Inspect(el, f)
+		}
+	case *IndexExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Index, f)
+	case *MemberExpression:
+		Inspect(n.Left, f)
+		Inspect(n.Property, f)
+	case *HashLiteral:
+		for _, key := range n.Keys {
+			Inspect(key, f)
+			Inspect(n.Pairs[key], f)
+		}
+	}
+}
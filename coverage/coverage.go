@@ -0,0 +1,233 @@
+// Package coverage records which statements and branches of scripts are
+// executed, and reports them as a summary, an LCOV trace file or an HTML
+// page.
+package coverage
+
+import (
+	"fmt"
+	"inter/ast"
+	"inter/evaluator"
+	"inter/object"
+	"io"
+	"sort"
+)
+
+// Profile counts the executions of the statements and if expressions of
+// the programs added to it. It implements evaluator.BranchHook, install it
+// with Interpreter.SetHook while the programs run.
+type Profile struct {
+	Files []*File
+
+	statements map[ast.Statement]*Statement
+	branches   map[*ast.IfExpression]*Branch
+}
+
+// File is the coverage of one source file.
+type File struct {
+	Name       string
+	Source     string
+	Statements []*Statement
+	Branches   []*Branch // in source order
+}
+
+// Statement counts the executions of a statement.
+type Statement struct {
+	Node  ast.Statement
+	Line  int
+	Count int
+}
+
+// Branch counts how often each arm of an if expression was taken. A
+// missing else arm is a branch too.
+type Branch struct {
+	Node       *ast.IfExpression
+	Line       int
+	Then, Else int
+}
+
+func New() *Profile {
+	return &Profile{
+		statements: make(map[ast.Statement]*Statement),
+		branches:   make(map[*ast.IfExpression]*Branch),
+	}
+}
+
+// Add registers the statements and branches of program, parsed from src
+// in the named file. Only added programs are counted.
+func (p *Profile) Add(name, src string, program *ast.Program) *File {
+	f := &File{Name: name, Source: src}
+	p.Files = append(p.Files, f)
+
+	add := func(list []ast.Statement) {
+		for _, st := range list {
+			s := &Statement{Node: st, Line: ast.StartToken(st).Line}
+			f.Statements = append(f.Statements, s)
+			p.statements[st] = s
+		}
+	}
+
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Program:
+			add(n.Statements)
+		case *ast.BlockStatement:
+			add(n.Statements)
+		case *ast.IfExpression:
+			b := &Branch{Node: n, Line: n.Token.Line}
+			f.Branches = append(f.Branches, b)
+			p.branches[n] = b
+		}
+		return true
+	})
+
+	sort.SliceStable(f.Statements, func(i, j int) bool {
+		return f.Statements[i].Line < f.Statements[j].Line
+	})
+	return f
+}
+
+func (p *Profile) BeforeStatement(in *evaluator.Interpreter, st ast.Statement, env *object.Environment) {
+	if s, ok := p.statements[st]; ok {
+		s.Count++
+	}
+}
+
+func (p *Profile) Branch(in *evaluator.Interpreter, exp *ast.IfExpression, taken bool) {
+	b, ok := p.branches[exp]
+	if !ok {
+		return
+	}
+	if taken {
+		b.Then++
+	} else {
+		b.Else++
+	}
+}
+
+// Counts reports how many statements and branch arms of f exist and how
+// many of them were executed.
+func (f *File) Counts() (statements, statementsHit, branches, branchesHit int) {
+	for _, s := range f.Statements {
+		statements++
+		if s.Count > 0 {
+			statementsHit++
+		}
+	}
+	for _, b := range f.Branches {
+		branches += 2
+		if b.Then > 0 {
+			branchesHit++
+		}
+		if b.Else > 0 {
+			branchesHit++
+		}
+	}
+	return
+}
+
+// Lines returns the execution count of each line holding the start of a
+// statement, the highest count if there are several.
+func (f *File) Lines() map[int]int {
+	lines := make(map[int]int)
+	for _, s := range f.Statements {
+		if count, ok := lines[s.Line]; !ok || s.Count > count {
+			lines[s.Line] = s.Count
+		}
+	}
+	return lines
+}
+
+// WriteSummary writes one line per file with the percentages of executed
+// statements and branches, followed by the total if there are several
+// files.
+func (p *Profile) WriteSummary(w io.Writer) error {
+	var st, stHit, br, brHit int
+	for _, f := range p.Files {
+		s, sHit, b, bHit := f.Counts()
+		st, stHit, br, brHit = st+s, stHit+sHit, br+b, brHit+bHit
+		if _, err := fmt.Fprintf(w, "%s: %s\n", f.Name, summary(s, sHit, b, bHit)); err != nil {
+			return err
+		}
+	}
+
+	if len(p.Files) < 2 {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "total: %s\n", summary(st, stHit, br, brHit))
+	return err
+}
+
+func summary(statements, statementsHit, branches, branchesHit int) string {
+	if statements == 0 {
+		return "no statements"
+	}
+	return fmt.Sprintf("%s of statements, %s of branches",
+		percent(statementsHit, statements), percent(branchesHit, branches))
+}
+
+// percent formats hit/total, a file without branches is fully covered.
+func percent(hit, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
+
+// WriteLCOV writes the profile as an LCOV trace file, as read by genhtml
+// and most coverage services.
+func (p *Profile) WriteLCOV(w io.Writer) error {
+	ew := &errWriter{w: w}
+	for _, f := range p.Files {
+		ew.printf("TN:\nSF:%s\n", f.Name)
+
+		hit := 0
+		for i, b := range f.Branches {
+			ew.printf("BRDA:%d,%d,0,%s\n", b.Line, i, taken(b, b.Then))
+			ew.printf("BRDA:%d,%d,1,%s\n", b.Line, i, taken(b, b.Else))
+			if b.Then > 0 {
+				hit++
+			}
+			if b.Else > 0 {
+				hit++
+			}
+		}
+		ew.printf("BRF:%d\nBRH:%d\n", 2*len(f.Branches), hit)
+
+		lines := f.Lines()
+		numbers := make([]int, 0, len(lines))
+		for line := range lines {
+			numbers = append(numbers, line)
+		}
+		sort.Ints(numbers)
+
+		hit = 0
+		for _, line := range numbers {
+			ew.printf("DA:%d,%d\n", line, lines[line])
+			if lines[line] > 0 {
+				hit++
+			}
+		}
+		ew.printf("LF:%d\nLH:%d\nend_of_record\n", len(numbers), hit)
+	}
+	return ew.err
+}
+
+// taken formats the count of an arm, "-" if its if expression never ran.
+func taken(b *Branch, count int) string {
+	if b.Then+b.Else == 0 {
+		return "-"
+	}
+	return fmt.Sprint(count)
+}
+
+// errWriter keeps the first error of a series of writes.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (ew *errWriter) printf(format string, args ...interface{}) {
+	if ew.err == nil {
+		_, ew.err = fmt.Fprintf(ew.w, format, args...)
+	}
+}
@@ -0,0 +1,135 @@
+package coverage
+
+import (
+	"bytes"
+	"context"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"strings"
+	"testing"
+)
+
+const src = `let abs = fn(n) {
+  if (n < 0) {
+    return -n;
+  }
+  n
+};
+let unused = fn() { 1 };
+abs(-1); abs(-2);
+`
+
+func run(t *testing.T, name, src string, p *Profile) {
+	t.Helper()
+
+	program := parser.New(lexer.New(src)).ParseProgram()
+	p.Add(name, src, program)
+
+	in := evaluator.New(context.Background(), evaluator.Limits{})
+	in.SetHook(p)
+	if err, ok := in.Eval(program, object.NewEnvironment()).(*object.Error); ok {
+		t.Fatalf("evaluation failed: %s", err.Message)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	p := New()
+	run(t, "abs.mk", src, p)
+
+	f := p.Files[0]
+	statements, statementsHit, branches, branchesHit := f.Counts()
+	if statements != 8 || statementsHit != 6 || branches != 2 || branchesHit != 1 {
+		t.Errorf("wrong counts. got=%d/%d statements, %d/%d branches", statementsHit, statements, branchesHit, branches)
+	}
+
+	// Line 7 counts as executed: the let is, the function body is not.
+	expected := map[int]int{1: 1, 2: 2, 3: 2, 5: 0, 7: 1, 8: 1}
+	lines := f.Lines()
+	if len(lines) != len(expected) {
+		t.Errorf("wrong lines. got=%v", lines)
+	}
+	for line, count := range expected {
+		if lines[line] != count {
+			t.Errorf("wrong count for line %d. expected=%d, got=%d", line, count, lines[line])
+		}
+	}
+
+	if b := f.Branches[0]; b.Then != 2 || b.Else != 0 {
+		t.Errorf("wrong branch counts. got=%d, %d", b.Then, b.Else)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	p := New()
+	run(t, "abs.mk", src, p)
+	run(t, "empty.mk", "", p)
+	run(t, "flat.mk", "let x = 1;", p)
+
+	var out bytes.Buffer
+	p.WriteSummary(&out)
+
+	expected := `abs.mk: 75.0% of statements, 50.0% of branches
+empty.mk: no statements
+flat.mk: 100.0% of statements, 100.0% of branches
+total: 77.8% of statements, 50.0% of branches
+`
+	if out.String() != expected {
+		t.Errorf("wrong summary.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	p := New()
+	run(t, "abs.mk", src+"let f = fn(x) { if (x) { 1 } };", p)
+
+	var out bytes.Buffer
+	if err := p.WriteLCOV(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `TN:
+SF:abs.mk
+BRDA:2,0,0,2
+BRDA:2,0,1,0
+BRDA:9,1,0,-
+BRDA:9,1,1,-
+BRF:4
+BRH:1
+DA:1,1
+DA:2,2
+DA:3,2
+DA:5,0
+DA:7,1
+DA:8,1
+DA:9,1
+LF:7
+LH:6
+end_of_record
+`
+	if out.String() != expected {
+		t.Errorf("wrong LCOV output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	p := New()
+	run(t, "abs.mk", src, p)
+
+	var out bytes.Buffer
+	if err := p.WriteHTML(&out); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		`<span class="line cov" title="executed 1 times"><span class="num">1</span>let abs = fn(n) {</span>`,
+		`<span class="line partial" title="then taken 2 times, else taken 0 times"><span class="num">2</span>  if (n &lt; 0) {</span>`,
+		`<span class="line nocov" title="executed 0 times"><span class="num">5</span>  n</span>`,
+		`<span class="line "><span class="num">6</span>};</span>`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("HTML report does not contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+type htmlLine struct {
+	Number int
+	Text   string
+	Class  string // "cov", "nocov" or "" for lines without statements
+	Title  string
+}
+
+type htmlFile struct {
+	Name    string
+	Summary string
+	Lines   []htmlLine
+}
+
+var htmlTemplate = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage</title>
+<style>
+body { font-family: sans-serif; margin: 1em; }
+pre { font-family: monospace; margin: 0; }
+.line { display: block; }
+.num { display: inline-block; width: 4em; color: #888; text-align: right; padding-right: 1em; user-select: none; }
+.cov { background: #dfd; }
+.nocov { background: #fdd; }
+.partial { background: #ffd; }
+</style>
+</head>
+<body>
+{{range .}}
+<h2>{{.Name}}</h2>
+<p>{{.Summary}}</p>
+<pre>{{range .Lines}}<span class="line {{.Class}}"{{if .Title}} title="{{.Title}}"{{end}}><span class="num">{{.Number}}</span>{{.Text}}</span>{{end}}</pre>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes the sources of the profiled files as an HTML page,
+// highlighting executed lines in green, lines never executed in red and
+// lines with an if expression taking only one of its arms in yellow.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	files := []htmlFile{}
+	for _, f := range p.Files {
+		lines := f.Lines()
+
+		branches := make(map[int]*Branch)
+		for _, b := range f.Branches {
+			if branches[b.Line] == nil || b.Then == 0 || b.Else == 0 {
+				branches[b.Line] = b
+			}
+		}
+
+		hf := htmlFile{Name: f.Name, Summary: summary(f.Counts())}
+		for i, text := range strings.Split(strings.TrimSuffix(f.Source, "\n"), "\n") {
+			line := htmlLine{Number: i + 1, Text: text}
+			if count, ok := lines[i+1]; ok {
+				line.Class = "cov"
+				line.Title = fmt.Sprintf("executed %d times", count)
+				if count == 0 {
+					line.Class = "nocov"
+				}
+			}
+			if b, ok := branches[i+1]; ok && b.Then+b.Else > 0 && (b.Then == 0 || b.Else == 0) {
+				line.Class = "partial"
+				line.Title = fmt.Sprintf("then taken %d times, else taken %d times", b.Then, b.Else)
+			}
+			hf.Lines = append(hf.Lines, line)
+		}
+		files = append(files, hf)
+	}
+
+	return htmlTemplate.Execute(w, files)
+}
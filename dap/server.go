@@ -0,0 +1,546 @@
+// Package dap implements a Debug Adapter Protocol server, exposing the step
+// debugger of package debug to editors.
+package dap
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inter/ast"
+	"inter/debug"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"inter/rpc"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// threadID is the only thread: programs run on a single interpreter.
+const threadID = 1
+
+var errNotStopped = errors.New("program is not stopped")
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launch,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"evaluate":          (*Server).evaluate,
+	"continue":          resumeWith(debug.Continue),
+	"next":              resumeWith(debug.StepOver),
+	"stepIn":            resumeWith(debug.StepInto),
+	"stepOut":           resumeWith(debug.StepOut),
+	"pause":             (*Server).pause,
+	"terminate":         (*Server).terminate,
+	"disconnect":        (*Server).terminate,
+}
+
+type Server struct {
+	writeMu sync.Mutex // guards out and seq
+	out     io.Writer
+	seq     int
+
+	debugger *debug.Debugger
+	ctx      context.Context
+	cancel   context.CancelFunc
+	resume   chan debug.Action
+	done     chan struct{} // closed once the program has ended
+
+	// Set by launch and configurationDone, and read by the program once
+	// started.
+	path       string
+	program    *ast.Program
+	noDebug    bool
+	configured bool
+	started    bool
+
+	// loader loads the imports of the program. Set by start, and read
+	// while the program is stopped.
+	loader *evaluator.Loader
+
+	// after runs once the response to the current request is written, so
+	// that the events it causes follow the response.
+	after func()
+
+	mu   sync.Mutex // guards stop and refs
+	stop *debug.Stop
+	refs []interface{} // values of the variable references, by reference-1
+}
+
+// Serve handles the requests read from r, writing responses and events to
+// w, until the client disconnects or r is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{
+		out:    w,
+		ctx:    ctx,
+		cancel: cancel,
+		resume: make(chan debug.Action, 1),
+		done:   make(chan struct{}),
+	}
+	s.debugger = debug.New(s.stopped)
+	defer s.shutdown()
+
+	in := bufio.NewReader(r)
+	for {
+		content, err := rpc.ReadMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			return err
+		}
+
+		if err := s.handle(req); err != nil {
+			return err
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(req request) error {
+	h, ok := handlers[req.Command]
+	if !ok {
+		return s.send(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command,
+			Message: "unsupported command: " + req.Command})
+	}
+
+	s.after = nil
+	body, err := h(s, req.Arguments)
+
+	resp := &response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: err == nil, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	if err := s.send(resp); err != nil {
+		return err
+	}
+
+	if s.after != nil {
+		s.after()
+	}
+	return nil
+}
+
+// send writes msg, a *response or an *event, numbering it.
+func (s *Server) send(msg interface{}) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	switch msg := msg.(type) {
+	case *response:
+		msg.Seq = s.seq
+	case *event:
+		msg.Seq = s.seq
+	}
+	return rpc.WriteMessage(s.out, msg)
+}
+
+func (s *Server) event(name string, body interface{}) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+// shutdown stops the program, if running, and waits for it to end.
+func (s *Server) shutdown() {
+	s.cancel()
+	if s.started {
+		<-s.done
+	}
+}
+
+func (s *Server) initialize(args json.RawMessage) (interface{}, error) {
+	s.after = func() { s.event("initialized", nil) }
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var a LaunchArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+	if s.program != nil {
+		return nil, errors.New("a program is already launched")
+	}
+
+	program, err := parseFile(a.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	s.path, s.program, s.noDebug = a.Program, program, a.NoDebug
+	if a.StopOnEntry {
+		s.debugger.StopOnEntry()
+	}
+	s.after = s.start
+	return nil, nil
+}
+
+func (s *Server) configurationDone(args json.RawMessage) (interface{}, error) {
+	s.configured = true
+	s.after = s.start
+	return nil, nil
+}
+
+// start runs the program once it is launched and configured.
+func (s *Server) start() {
+	if s.program == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+
+	sandbox := evaluator.NewSandbox(evaluator.AllCapabilities...)
+	sandbox.Stdout = outputWriter{s}
+	env := sandbox.NewEnvironment()
+	s.loader = sandbox.NewLoader(evaluator.SearchPath()...)
+	s.loader.SetFile(env, s.path)
+
+	go func() {
+		defer close(s.done)
+
+		in := evaluator.New(s.ctx, evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth})
+		in.SetLoader(s.loader)
+		if !s.noDebug {
+			in.SetHook(s.debugger)
+		}
+
+		exitCode := 0
+		if err, ok := in.Eval(s.program, env).(*object.Error); ok {
+			exitCode = 1
+			if s.ctx.Err() == nil {
+				s.event("output", OutputEvent{Category: "stderr", Output: err.Traceback(s.path) + "\n"})
+			}
+		}
+		s.event("exited", ExitedEvent{ExitCode: exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// stopped is called by the debugger on the program goroutine, and blocks
+// until the client resumes the program.
+func (s *Server) stopped(stop *debug.Stop) debug.Action {
+	s.mu.Lock()
+	s.stop, s.refs = stop, nil
+	s.mu.Unlock()
+
+	s.event("stopped", StoppedEvent{Reason: stop.Reason, ThreadID: threadID, AllThreadsStopped: true})
+
+	select {
+	case action := <-s.resume:
+		return action
+	case <-s.ctx.Done():
+		return debug.Continue
+	}
+}
+
+// resumeWith returns the handler of a request resuming the program with action.
+func resumeWith(action debug.Action) handler {
+	return func(s *Server, args json.RawMessage) (interface{}, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.stop == nil {
+			return nil, errNotStopped
+		}
+		s.stop, s.refs = nil, nil
+		s.after = func() { s.resume <- action }
+
+		if action == debug.Continue {
+			return map[string]bool{"allThreadsContinued": true}, nil
+		}
+		return nil, nil
+	}
+}
+
+func (s *Server) pause(args json.RawMessage) (interface{}, error) {
+	s.debugger.Pause()
+	return nil, nil
+}
+
+func (s *Server) terminate(args json.RawMessage) (interface{}, error) {
+	s.shutdown()
+	return nil, nil
+}
+
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var a SetBreakpointsArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	// Breakpoints can be set before launch: read the source to check them.
+	var lines map[int]bool
+	var message string
+	switch program, err := parseFile(a.Source.Path); {
+	case s.program != nil && !samePath(a.Source.Path, s.path):
+		message = "not the launched program"
+	case err != nil:
+		message = err.Error()
+	default:
+		lines = statementLines(program)
+	}
+
+	for _, line := range s.debugger.Breakpoints() {
+		s.debugger.ClearBreakpoint(line)
+	}
+
+	breakpoints := []Breakpoint{}
+	for _, b := range a.Breakpoints {
+		bp := Breakpoint{Line: b.Line, Verified: lines[b.Line], Message: message}
+		if bp.Verified {
+			s.debugger.SetBreakpoint(b.Line)
+		} else if bp.Message == "" {
+			bp.Message = "no statement on this line"
+		}
+		breakpoints = append(breakpoints, bp)
+	}
+	return SetBreakpointsResponse{Breakpoints: breakpoints}, nil
+}
+
+func (s *Server) threads(args json.RawMessage) (interface{}, error) {
+	return ThreadsResponse{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+}
+
+func (s *Server) stackTrace(args json.RawMessage) (interface{}, error) {
+	var a StackTraceArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+
+	frames := []StackFrame{}
+	for i, f := range s.stop.Frames {
+		// Frames in imported modules show the file of the module.
+		path := s.path
+		if file := s.loader.File(f.Env); file != "" {
+			path = file
+		}
+		frame := StackFrame{ID: i + 1, Name: f.Name(), Source: Source{Name: filepath.Base(path), Path: path}}
+		if f.Statement != nil {
+			tok := ast.StartToken(f.Statement)
+			frame.Line, frame.Column = tok.Line, tok.Column
+		}
+		frames = append(frames, frame)
+	}
+
+	total := len(frames)
+	if a.StartFrame > 0 && a.StartFrame <= len(frames) {
+		frames = frames[a.StartFrame:]
+	}
+	if a.Levels > 0 && a.Levels < len(frames) {
+		frames = frames[:a.Levels]
+	}
+	return StackTraceResponse{StackFrames: frames, TotalFrames: total}, nil
+}
+
+// frameEnv returns the environment of a frame of the current stop, the
+// innermost one for id 0. s.mu must be held.
+func (s *Server) frameEnv(id int) (*object.Environment, error) {
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+	if id == 0 {
+		return s.stop.Env, nil
+	}
+	if id < 1 || id > len(s.stop.Frames) {
+		return nil, fmt.Errorf("unknown frame %d", id)
+	}
+	return s.stop.Frames[id-1].Env, nil
+}
+
+// reference returns a variable reference to val, an environment or an
+// object with elements, or 0 when it has nothing to expand. References are
+// only valid until the program resumes. s.mu must be held.
+func (s *Server) reference(val interface{}) int {
+	switch val := val.(type) {
+	case *object.Array:
+		if len(val.Elements) == 0 {
+			return 0
+		}
+	case *object.Hash:
+		if len(val.Pairs) == 0 {
+			return 0
+		}
+	case *object.Environment:
+	default:
+		return 0
+	}
+
+	s.refs = append(s.refs, val)
+	return len(s.refs)
+}
+
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var a ScopesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env, err := s.frameEnv(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := []Scope{}
+	for _, scope := range debug.Scopes(env) {
+		scopes = append(scopes, Scope{Name: scope.Name, VariablesReference: s.reference(scope.Env)})
+	}
+	return ScopesResponse{Scopes: scopes}, nil
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var a VariablesArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+	if a.VariablesReference < 1 || a.VariablesReference > len(s.refs) {
+		return nil, fmt.Errorf("unknown variables reference %d", a.VariablesReference)
+	}
+
+	vars := []Variable{}
+	add := func(name string, val object.Object) {
+		vars = append(vars, Variable{
+			Name:               name,
+			Value:              debug.Inspect(val),
+			Type:               string(val.Type()),
+			VariablesReference: s.reference(val),
+		})
+	}
+
+	switch val := s.refs[a.VariablesReference-1].(type) {
+	case *object.Environment:
+		for _, v := range debug.Variables(val) {
+			add(v.Name, v.Value)
+		}
+	case *object.Array:
+		for i, el := range val.Elements {
+			add(strconv.Itoa(i), el)
+		}
+	case *object.Hash:
+		pairs := []object.HashPair{}
+		for _, pair := range val.Pairs {
+			pairs = append(pairs, pair)
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key.Inspect() < pairs[j].Key.Inspect() })
+		for _, pair := range pairs {
+			add(pair.Key.Inspect(), pair.Value)
+		}
+	}
+	return VariablesResponse{Variables: vars}, nil
+}
+
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	var a EvaluateArguments
+	if err := decode(args, &a); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	env, err := s.frameEnv(a.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := debug.Evaluate(s.ctx, a.Expression, env)
+	if err != nil {
+		return nil, err
+	}
+	return EvaluateResponse{
+		Result:             debug.Inspect(val),
+		Type:               string(val.Type()),
+		VariablesReference: s.reference(val),
+	}, nil
+}
+
+// outputWriter sends what the program prints as output events.
+type outputWriter struct {
+	s *Server
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.s.event("output", OutputEvent{Category: "stdout", Output: string(p)})
+	return len(p), nil
+}
+
+// decode unmarshals the arguments of a request, which may be omitted.
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+	return json.Unmarshal(args, v)
+}
+
+func parseFile(path string) (*ast.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s:%s", path, errs[0])
+	}
+	return program, nil
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+// statementLines returns the lines on which a statement starts, where a
+// breakpoint can hit.
+func statementLines(program *ast.Program) map[int]bool {
+	lines := make(map[int]bool)
+	ast.Inspect(program, func(n ast.Node) bool {
+		if st, ok := n.(ast.Statement); ok {
+			if _, ok := st.(*ast.BlockStatement); !ok {
+				lines[ast.StartToken(st).Line] = true
+			}
+		}
+		return true
+	})
+	return lines
+}
@@ -0,0 +1,336 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"inter/rpc"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let xs = [1, {"k": 2}];
+puts(add(1, 2));
+add(3, 4);
+`
+
+// client talks to a server running in the background.
+type client struct {
+	t        *testing.T
+	w        *io.PipeWriter
+	messages chan map[string]interface{}
+	served   chan error
+	seq      int
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:        t,
+		w:        clientOut,
+		messages: make(chan map[string]interface{}, 100),
+		served:   make(chan error, 1),
+	}
+
+	go func() {
+		c.served <- Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := bufio.NewReader(clientIn)
+		for {
+			content, err := rpc.ReadMessage(r)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]interface{}
+			json.Unmarshal(content, &msg)
+			c.messages <- msg
+		}
+	}()
+
+	return c
+}
+
+func (c *client) send(command string, args interface{}) int {
+	c.seq++
+	msg := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		msg["arguments"] = args
+	}
+	if err := rpc.WriteMessage(c.w, msg); err != nil {
+		c.t.Fatalf("WriteMessage failed: %s", err)
+	}
+	return c.seq
+}
+
+// next returns the next message, failing the test if it is not of the given
+// type and name: the command of a response or the event of an event.
+func (c *client) next(typ, name string) map[string]interface{} {
+	c.t.Helper()
+
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("connection closed, expected %s %s", typ, name)
+		}
+		key := "command"
+		if typ == "event" {
+			key = "event"
+		}
+		if msg["type"] != typ || msg[key] != name {
+			c.t.Fatalf("expected %s %s. got=%v", typ, name, msg)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("timeout waiting for %s %s", typ, name)
+		return nil
+	}
+}
+
+// call sends a request and returns the body of its successful response.
+func (c *client) call(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	c.send(command, args)
+	resp := c.next("response", command)
+	if resp["success"] != true {
+		c.t.Fatalf("%s failed: %v", command, resp["message"])
+	}
+	body, _ := resp["body"].(map[string]interface{})
+	return body
+}
+
+func (c *client) stopped(reason string) {
+	c.t.Helper()
+	if got := c.next("event", "stopped")["body"].(map[string]interface{})["reason"]; got != reason {
+		c.t.Fatalf("wrong stop reason. expected=%s, got=%v", reason, got)
+	}
+}
+
+// frames returns the names and lines of the stack frames.
+func (c *client) frames() []string {
+	c.t.Helper()
+
+	var frames []string
+	for _, f := range c.call("stackTrace", map[string]int{"threadId": threadID})["stackFrames"].([]interface{}) {
+		f := f.(map[string]interface{})
+		frames = append(frames, fmt.Sprintf("%v:%v", f["name"], f["line"]))
+	}
+	return frames
+}
+
+// variables returns the variables of a reference as name=value pairs, and
+// the references to their children by name.
+func (c *client) variables(ref float64) ([]string, map[string]float64) {
+	c.t.Helper()
+
+	var vars []string
+	refs := make(map[string]float64)
+	for _, v := range c.call("variables", map[string]float64{"variablesReference": ref})["variables"].([]interface{}) {
+		v := v.(map[string]interface{})
+		vars = append(vars, v["name"].(string)+"="+v["value"].(string))
+		refs[v["name"].(string)] = v["variablesReference"].(float64)
+	}
+	return vars, refs
+}
+
+func writeProgram(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte(program), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func expect(t *testing.T, what string, got, expected interface{}) {
+	t.Helper()
+	g, _ := json.Marshal(got)
+	e, _ := json.Marshal(expected)
+	if string(g) != string(e) {
+		t.Errorf("wrong %s.\nexpected=%s\ngot=     %s", what, e, g)
+	}
+}
+
+func TestSession(t *testing.T) {
+	path := writeProgram(t)
+	c := newClient(t)
+
+	caps := c.call("initialize", map[string]string{"adapterID": "inter"})
+	if caps["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("wrong capabilities. got=%v", caps)
+	}
+	c.next("event", "initialized")
+
+	c.call("launch", map[string]interface{}{"program": path})
+	body := c.call("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]int{{"line": 2}, {"line": 4}},
+	})
+	expect(t, "breakpoints", body["breakpoints"], []map[string]interface{}{
+		{"verified": true, "line": 2},
+		{"verified": false, "line": 4, "message": "no statement on this line"},
+	})
+	c.call("configurationDone", nil)
+	c.stopped("breakpoint")
+
+	expect(t, "threads", c.call("threads", nil)["threads"], []Thread{{ID: 1, Name: "main"}})
+	expect(t, "frames", c.frames(), []string{"add:2", "<main>:6"})
+
+	scopes := c.call("scopes", map[string]int{"frameId": 1})["scopes"].([]interface{})
+	if len(scopes) != 2 {
+		t.Fatalf("wrong number of scopes. got=%v", scopes)
+	}
+	locals, _ := c.variables(scopes[0].(map[string]interface{})["variablesReference"].(float64))
+	expect(t, "locals", locals, []string{"a=1", "b=2"})
+
+	globals, refs := c.variables(scopes[1].(map[string]interface{})["variablesReference"].(float64))
+	expect(t, "globals", globals, []string{"add=fn(a, b) { ... }", "xs=[1, {k: 2}]"})
+	xs, refs := c.variables(refs["xs"])
+	expect(t, "elements", xs, []string{"0=1", "1={k: 2}"})
+	hash, _ := c.variables(refs["1"])
+	expect(t, "pairs", hash, []string{"k=2"})
+
+	expect(t, "evaluation", c.call("evaluate", map[string]interface{}{"expression": "a + b", "frameId": 1})["result"], "3")
+	expect(t, "evaluation", c.call("evaluate", map[string]interface{}{"expression": "xs[0] + 1", "frameId": 2})["result"], "2")
+
+	c.call("next", map[string]int{"threadId": threadID})
+	c.stopped("step")
+	expect(t, "frames", c.frames(), []string{"add:3", "<main>:6"})
+	expect(t, "evaluation", c.call("evaluate", map[string]interface{}{"expression": "sum * 10"})["result"], "30")
+
+	c.call("stepOut", map[string]int{"threadId": threadID})
+	output := c.next("event", "output")["body"].(map[string]interface{})
+	expect(t, "output", output, OutputEvent{Category: "stdout", Output: "3\n"})
+	c.stopped("step")
+	expect(t, "frames", c.frames(), []string{"<main>:7"})
+
+	c.call("continue", map[string]int{"threadId": threadID})
+	c.stopped("breakpoint")
+	c.call("continue", map[string]int{"threadId": threadID})
+	expect(t, "exit code", c.next("event", "exited")["body"], ExitedEvent{ExitCode: 0})
+	c.next("event", "terminated")
+
+	c.call("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.mk")
+	if err := os.WriteFile(path, []byte(`import "./lib"; puts(lib.x);`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "lib.mk"), []byte(`let x = 2;`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.call("initialize", map[string]string{"adapterID": "inter"})
+	c.next("event", "initialized")
+	c.call("launch", map[string]interface{}{"program": path, "stopOnEntry": true})
+	c.call("configurationDone", nil)
+	c.stopped("entry")
+
+	// Stepping into the import stops in the module, shown in its own file.
+	c.call("stepIn", map[string]int{"threadId": threadID})
+	c.stopped("step")
+	var sources []string
+	for _, f := range c.call("stackTrace", map[string]int{"threadId": threadID})["stackFrames"].([]interface{}) {
+		source := f.(map[string]interface{})["source"].(map[string]interface{})
+		sources = append(sources, fmt.Sprintf("%v %v", source["name"], source["path"]))
+	}
+	expect(t, "sources", sources, []string{"lib.mk " + filepath.Join(dir, "lib.mk"), "main.mk " + path})
+
+	c.call("continue", map[string]int{"threadId": threadID})
+	output := c.next("event", "output")["body"].(map[string]interface{})
+	expect(t, "output", output, OutputEvent{Category: "stdout", Output: "2\n"})
+	expect(t, "exit code", c.next("event", "exited")["body"], ExitedEvent{ExitCode: 0})
+	c.next("event", "terminated")
+
+	c.call("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestRunawayRecursion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.mk")
+	if err := os.WriteFile(path, []byte("let go = fn(n) { go(n + 1) + 1 };\ngo(0);"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := newClient(t)
+	c.call("initialize", map[string]string{"adapterID": "inter"})
+	c.next("event", "initialized")
+	c.call("launch", map[string]interface{}{"program": path})
+	c.call("configurationDone", nil)
+
+	output := c.next("event", "output")["body"].(map[string]interface{})
+	if !strings.HasPrefix(output["output"].(string), "maximum call depth exceeded\n") {
+		t.Errorf("wrong output. got=%.80q", output["output"])
+	}
+	expect(t, "exit code", c.next("event", "exited")["body"], ExitedEvent{ExitCode: 1})
+	c.next("event", "terminated")
+
+	c.call("disconnect", nil)
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	path := writeProgram(t)
+	c := newClient(t)
+
+	tests := []struct {
+		command  string
+		args     interface{}
+		expected string
+	}{
+		{"stackTrace", map[string]int{"threadId": threadID}, "program is not stopped"},
+		{"continue", nil, "program is not stopped"},
+		{"launch", map[string]string{"program": path + ".missing"}, "open " + path + ".missing: no such file or directory"},
+		{"attach", nil, "unsupported command: attach"},
+	}
+
+	for _, tt := range tests {
+		c.send(tt.command, tt.args)
+		resp := c.next("response", tt.command)
+		if resp["success"] != false || resp["message"] != tt.expected {
+			t.Errorf("expected %s to fail with %q. got=%v", tt.command, tt.expected, resp)
+		}
+	}
+
+	c.call("launch", map[string]interface{}{"program": path, "stopOnEntry": true})
+	c.call("configurationDone", nil)
+	c.stopped("entry")
+
+	c.send("evaluate", map[string]interface{}{"expression": "nope"})
+	if resp := c.next("response", "evaluate"); resp["message"] != "identifier not found: nope" {
+		t.Errorf("wrong evaluation error. got=%v", resp)
+	}
+
+	// Disconnecting stops the paused program.
+	c.send("disconnect", nil)
+	c.next("event", "exited")
+	c.next("event", "terminated")
+	c.next("response", "disconnect")
+	if err := <-c.served; err != nil {
+		t.Errorf("Serve failed: %s", err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"inter/dap"
+	"os"
+)
+
+func dapCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "usage: inter dap\n")
+		return 2
+	}
+
+	if err := dap.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package debug
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"inter/ast"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const prompt = "(debug) "
+
+const help = `commands:
+  break LINE, b LINE    set a breakpoint
+  clear LINE            remove a breakpoint
+  breakpoints           list the breakpoints
+  continue, c           run until the next breakpoint
+  step, s               step into function calls
+  next, n               step over function calls
+  out, o                step out of the current function
+  print EXPR, p EXPR    evaluate EXPR in the current environment
+  vars                  print the variables in scope
+  stack, bt             print the call stack
+  list, l               print the source around the current line
+  quit, q               stop the program
+An empty line repeats the previous command.
+`
+
+// console is the terminal front end of a Debugger.
+type console struct {
+	name    string
+	lines   []string
+	scanner *bufio.Scanner
+	out     io.Writer
+	d       *Debugger
+	ctx     context.Context
+	cancel  context.CancelFunc
+	last    string
+}
+
+// Run evaluates the program in src under the debugger, stopping before its
+// first statement and reading commands from r. name is the file name shown
+// in locations. The imports of the program are loaded by loader, relative
+// to name, and fail if loader is nil. Run returns the value of the program,
+// or an error if it does not parse, fails or the user quits.
+func Run(name, src string, env *object.Environment, loader *evaluator.Loader, r io.Reader, w io.Writer) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s:%s", name, errs[0])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c := &console{
+		name:    name,
+		lines:   strings.Split(src, "\n"),
+		scanner: bufio.NewScanner(r),
+		out:     w,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	c.d = New(c.stop)
+	c.d.StopOnEntry()
+
+	in := evaluator.New(ctx, evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth})
+	in.SetHook(c.d)
+	if loader != nil {
+		loader.SetFile(env, name)
+		in.SetLoader(loader)
+	}
+	val := in.Eval(program, env)
+
+	if ctx.Err() != nil {
+		return nil, errors.New("program stopped")
+	}
+	if err, ok := val.(*object.Error); ok {
+		return nil, errors.New(err.Traceback(name))
+	}
+	return val, nil
+}
+
+func (c *console) stop(s *Stop) Action {
+	fmt.Fprintf(c.out, "stopped at %s:%d (%s)\n", c.name, s.Line, s.Reason)
+	c.printLine(s.Line, "")
+
+	for {
+		fmt.Fprint(c.out, prompt)
+		if !c.scanner.Scan() {
+			fmt.Fprintln(c.out)
+			c.cancel()
+			return Continue
+		}
+
+		line := strings.TrimSpace(c.scanner.Text())
+		if line == "" {
+			line = c.last
+		}
+		c.last = line
+
+		if action, resume := c.command(line, s); resume {
+			return action
+		}
+	}
+}
+
+// command runs one command line, reporting whether and how the evaluation
+// resumes.
+func (c *console) command(line string, s *Stop) (Action, bool) {
+	cmd, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+
+	switch cmd {
+	case "":
+	case "continue", "c":
+		return Continue, true
+	case "step", "s":
+		return StepInto, true
+	case "next", "n":
+		return StepOver, true
+	case "out", "o":
+		return StepOut, true
+	case "quit", "q":
+		c.cancel()
+		return Continue, true
+
+	case "break", "b", "clear":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > len(c.lines) {
+			fmt.Fprintf(c.out, "invalid line: %q\n", arg)
+			break
+		}
+		if cmd == "clear" {
+			c.d.ClearBreakpoint(n)
+			fmt.Fprintf(c.out, "breakpoint cleared at %s:%d\n", c.name, n)
+		} else {
+			c.d.SetBreakpoint(n)
+			fmt.Fprintf(c.out, "breakpoint set at %s:%d\n", c.name, n)
+		}
+	case "breakpoints":
+		for _, n := range c.d.Breakpoints() {
+			fmt.Fprintf(c.out, "%s:%d\n", c.name, n)
+		}
+
+	case "print", "p":
+		val, err := Evaluate(c.ctx, arg, s.Env)
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %s\n", err)
+			break
+		}
+		fmt.Fprintln(c.out, val.Inspect())
+	case "vars":
+		for _, scope := range Scopes(s.Env) {
+			fmt.Fprintf(c.out, "%s:\n", scope.Name)
+			for _, v := range scope.Variables {
+				fmt.Fprintf(c.out, "  %s = %s\n", v.Name, Inspect(v.Value))
+			}
+		}
+	case "stack", "bt":
+		for i, f := range s.Frames {
+			fmt.Fprintf(c.out, "#%d %s at %s:%d\n", i, f.Name(), c.name, statementLine(f.Statement))
+		}
+	case "list", "l":
+		for n := s.Line - 3; n <= s.Line+3; n++ {
+			marker := "  "
+			if n == s.Line {
+				marker = "=>"
+			}
+			c.printLine(n, marker)
+		}
+	case "help", "h":
+		fmt.Fprint(c.out, help)
+	default:
+		fmt.Fprintf(c.out, "unknown command: %s, type help for a list\n", cmd)
+	}
+	return Continue, false
+}
+
+func (c *console) printLine(n int, marker string) {
+	if n < 1 || n > len(c.lines) {
+		return
+	}
+	fmt.Fprintf(c.out, "%2s %4d\t%s\n", marker, n, c.lines[n-1])
+}
+
+func statementLine(st ast.Statement) int {
+	if st == nil {
+		return 0
+	}
+	return ast.StartToken(st).Line
+}
+
+// Inspect is like the Inspect method of val, but shortens functions to their
+// first line.
+func Inspect(val object.Object) string {
+	s := val.Inspect()
+	if i := strings.Index(s, "\n"); i >= 0 {
+		return s[:i] + " ... }"
+	}
+	return s
+}
@@ -0,0 +1,212 @@
+// Package debug implements a step debugger on top of the evaluator hooks:
+// breakpoints by line, stepping into, over and out of function calls, and
+// inspection of the paused environment and call stack.
+package debug
+
+import (
+	"context"
+	"errors"
+	"inter/ast"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Action tells the debugger how to resume after a stop.
+type Action int
+
+const (
+	Continue Action = iota // run until the next breakpoint
+	StepInto               // stop at the next statement
+	StepOver               // stop at the next statement of the same or an outer frame
+	StepOut                // stop at the next statement of an outer frame
+)
+
+// Reasons for a stop.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Stop describes where the evaluation is suspended.
+type Stop struct {
+	Reason    string
+	Line      int
+	Statement ast.Statement
+	Env       *object.Environment
+	Frames    []evaluator.Frame // innermost first
+}
+
+// Debugger is an evaluator.Hook calling OnStop whenever the evaluation stops
+// before a statement. The evaluation resumes once OnStop returns. Breakpoints
+// can be changed and Pause called from other goroutines.
+type Debugger struct {
+	OnStop func(*Stop) Action
+
+	mu          sync.Mutex
+	breakpoints map[int]bool
+	pause       string // the reason of a requested stop
+	action      Action
+	depth       int // of the stack when the action was chosen
+	line        int // of the previous statement
+	lineDepth   int
+}
+
+func New(onStop func(*Stop) Action) *Debugger {
+	return &Debugger{OnStop: onStop, breakpoints: make(map[int]bool)}
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	d.breakpoints[line] = true
+	d.mu.Unlock()
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	delete(d.breakpoints, line)
+	d.mu.Unlock()
+}
+
+// Breakpoints returns the lines with a breakpoint, in order.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := []int{}
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// Pause stops the evaluation before the next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = ReasonPause
+	d.mu.Unlock()
+}
+
+// StopOnEntry stops the evaluation before its first statement. It must be
+// called before the evaluation starts.
+func (d *Debugger) StopOnEntry() {
+	d.mu.Lock()
+	d.pause = ReasonEntry
+	d.mu.Unlock()
+}
+
+func (d *Debugger) BeforeStatement(in *evaluator.Interpreter, st ast.Statement, env *object.Environment) {
+	line := ast.StartToken(st).Line
+	depth := in.Depth()
+
+	d.mu.Lock()
+	reason := ""
+	switch {
+	case d.pause != "":
+		reason = d.pause
+		d.pause = ""
+	case d.action == StepInto,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		reason = ReasonStep
+	case d.breakpoints[line] && (line != d.line || depth != d.lineDepth):
+		// A breakpoint only hits once for a line holding several statements.
+		reason = ReasonBreakpoint
+	}
+	d.line, d.lineDepth = line, depth
+	d.mu.Unlock()
+
+	if reason == "" {
+		return
+	}
+
+	action := d.OnStop(&Stop{
+		Reason:    reason,
+		Line:      line,
+		Statement: st,
+		Env:       env,
+		Frames:    in.Frames(),
+	})
+
+	d.mu.Lock()
+	d.action, d.depth = action, depth
+	d.mu.Unlock()
+}
+
+// EvaluateLimits bounds the evaluations of Evaluate, so that a runaway
+// expression fails instead of hanging or crashing the debugger.
+var EvaluateLimits = evaluator.Limits{MaxDepth: 500, MaxSteps: 1000000}
+
+// Evaluate evaluates the expression or statements in src in env, typically
+// the environment of a stop, until it finishes, ctx is done or it hits
+// EvaluateLimits. Bindings made by src are kept in env.
+func Evaluate(ctx context.Context, src string, env *object.Environment) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "; "))
+	}
+
+	val := evaluator.EvalContext(ctx, program, env, EvaluateLimits)
+	if val == nil {
+		return object.NULL, nil
+	}
+	if err, ok := val.(*object.Error); ok {
+		return nil, errors.New(err.Message)
+	}
+	return val, nil
+}
+
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Scope is one environment of the chain visible from a stop.
+type Scope struct {
+	Name      string // "Locals", "Closure" or "Globals"
+	Env       *object.Environment
+	Variables []Variable // sorted by name, without builtins
+}
+
+// Scopes walks the environment chain from env outwards.
+func Scopes(env *object.Environment) []Scope {
+	scopes := []Scope{}
+	for e := env; e != nil; e = e.Outer() {
+		name := "Closure"
+		switch {
+		case e.Outer() == nil:
+			name = "Globals"
+		case e == env:
+			name = "Locals"
+		}
+
+		scopes = append(scopes, Scope{Name: name, Env: e, Variables: Variables(e)})
+	}
+	return scopes
+}
+
+// Variables returns the bindings of env, without the ones of its enclosing
+// environments and without builtins, sorted by name.
+func Variables(env *object.Environment) []Variable {
+	vars := []Variable{}
+	for name, val := range env.Locals() {
+		if val.Type() == object.BUILTIN_OBJ {
+			continue
+		}
+		if mod, ok := val.(*object.Module); ok && mod.Builtin() {
+			continue
+		}
+		vars = append(vars, Variable{Name: name, Value: val})
+	}
+
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars
+}
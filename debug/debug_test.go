@@ -0,0 +1,238 @@
+package debug
+
+import (
+	"bytes"
+	"context"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const program = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = x;
+add(x, y);
+`
+
+type stop struct {
+	reason string
+	line   int
+	depth  int
+}
+
+// debug runs program, answering the stops with actions in order and
+// continuing once they run out.
+func debug(t *testing.T, breakpoints []int, entry bool, actions ...Action) []stop {
+	t.Helper()
+
+	var stops []stop
+	d := New(func(s *Stop) Action {
+		stops = append(stops, stop{s.Reason, s.Line, len(s.Frames)})
+		if len(stops) > len(actions) {
+			return Continue
+		}
+		return actions[len(stops)-1]
+	})
+	for _, line := range breakpoints {
+		d.SetBreakpoint(line)
+	}
+	if entry {
+		d.StopOnEntry()
+	}
+
+	p := parser.New(lexer.New(program))
+	in := evaluator.New(context.Background(), evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth})
+	in.SetHook(d)
+	if val := in.Eval(p.ParseProgram(), object.NewEnvironment()); val.Inspect() != "6" {
+		t.Fatalf("wrong result. got=%s", val.Inspect())
+	}
+	return stops
+}
+
+func TestStepping(t *testing.T) {
+	tests := []struct {
+		breakpoints []int
+		entry       bool
+		actions     []Action
+		expected    []stop
+	}{
+		{nil, false, nil, nil},
+		{[]int{2}, false, nil, []stop{{ReasonBreakpoint, 2, 2}, {ReasonBreakpoint, 2, 2}}},
+		{[]int{6}, true, nil, []stop{{ReasonEntry, 1, 1}, {ReasonBreakpoint, 6, 1}}},
+		{nil, true, []Action{StepOver, StepOver, StepOver, Continue}, []stop{
+			{ReasonEntry, 1, 1}, {ReasonStep, 5, 1}, {ReasonStep, 6, 1}, {ReasonStep, 7, 1},
+		}},
+		{nil, true, []Action{StepOver, StepInto, StepInto, StepInto, StepInto, Continue}, []stop{
+			{ReasonEntry, 1, 1}, {ReasonStep, 5, 1}, {ReasonStep, 2, 2}, {ReasonStep, 3, 2}, {ReasonStep, 6, 1}, {ReasonStep, 7, 1},
+		}},
+		{[]int{2}, false, []Action{StepOut, Continue}, []stop{
+			{ReasonBreakpoint, 2, 2}, {ReasonStep, 6, 1}, {ReasonBreakpoint, 2, 2},
+		}},
+	}
+
+	for i, tt := range tests {
+		stops := debug(t, tt.breakpoints, tt.entry, tt.actions...)
+		if len(stops) != len(tt.expected) {
+			t.Errorf("tests[%d] - wrong number of stops. expected=%v, got=%v", i, tt.expected, stops)
+			continue
+		}
+		for j, s := range stops {
+			if s != tt.expected[j] {
+				t.Errorf("tests[%d] - wrong stop %d. expected=%v, got=%v", i, j, tt.expected[j], s)
+			}
+		}
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New(nil)
+	d.SetBreakpoint(7)
+	d.SetBreakpoint(2)
+	d.SetBreakpoint(7)
+	d.ClearBreakpoint(3)
+
+	if got := d.Breakpoints(); len(got) != 2 || got[0] != 2 || got[1] != 7 {
+		t.Errorf("wrong breakpoints. got=%v", got)
+	}
+
+	d.ClearBreakpoint(2)
+	if got := d.Breakpoints(); len(got) != 1 || got[0] != 7 {
+		t.Errorf("wrong breakpoints. got=%v", got)
+	}
+}
+
+func TestScopesAndEvaluate(t *testing.T) {
+	globals := object.NewEnvironment()
+	globals.Set("len", &object.Builtin{})
+	globals.Set("g", &object.Integer{Value: 1})
+	closure := object.NewEnclosedEnv(globals)
+	closure.Set("c", &object.Integer{Value: 2})
+	locals := object.NewEnclosedEnv(closure)
+	locals.Set("b", &object.Integer{Value: 3})
+	locals.Set("a", &object.Integer{Value: 4})
+
+	var names []string
+	for _, scope := range Scopes(locals) {
+		vars := []string{}
+		for _, v := range scope.Variables {
+			vars = append(vars, v.Name+"="+v.Value.Inspect())
+		}
+		names = append(names, scope.Name+": "+strings.Join(vars, " "))
+	}
+
+	expected := "Locals: a=4 b=3|Closure: c=2|Globals: g=1"
+	if got := strings.Join(names, "|"); got != expected {
+		t.Errorf("wrong scopes. expected=%q, got=%q", expected, got)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + c * g", "6"},
+		{"let d = a * 2; d", "8"},
+		{"d", "8"},
+		{"e", "error: identifier not found: e"},
+		{"let = 1", "error: Expected=\"IDENT\", got=\"=\"; no prefix parse function for = found"},
+		{"let f = fn() { 1 + f() }; f()", "error: maximum call depth exceeded"},
+		{"let h = fn() { h() }; h()", "error: maximum evaluation steps exceeded"},
+	}
+
+	for _, tt := range tests {
+		val, err := Evaluate(context.Background(), tt.input, locals)
+		got := ""
+		if err != nil {
+			got = "error: " + err.Error()
+		} else {
+			got = val.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	input := strings.Join([]string{
+		"b 2", "c", "vars", "bt", "n", "p sum * 10", "", "bogus", "clear 2", "c",
+	}, "\n")
+
+	var out bytes.Buffer
+	val, err := Run("main.mk", program, object.NewEnvironment(), nil, strings.NewReader(input), &out)
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if val.Inspect() != "6" {
+		t.Errorf("wrong result. got=%s", val.Inspect())
+	}
+
+	expected := `stopped at main.mk:1 (entry)
+      1	let add = fn(a, b) {
+(debug) breakpoint set at main.mk:2
+(debug) stopped at main.mk:2 (breakpoint)
+      2	  let sum = a + b;
+(debug) Locals:
+  a = 1
+  b = 2
+Globals:
+  add = fn(a, b) { ... }
+(debug) #0 add at main.mk:2
+#1 <main> at main.mk:5
+(debug) stopped at main.mk:3 (step)
+      3	  sum
+(debug) 30
+(debug) 30
+(debug) unknown command: bogus, type help for a list
+(debug) breakpoint cleared at main.mk:2
+(debug) `
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected=%q\ngot=     %q", expected, out.String())
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "lib.mk"), []byte("let x = 2;"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sandbox := evaluator.NewSandbox(evaluator.CapFS)
+	val, err := Run(filepath.Join(dir, "main.mk"), `import "./lib"; lib.x * 3`, sandbox.NewEnvironment(), sandbox.NewLoader(), strings.NewReader("c"), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if val.Inspect() != "6" {
+		t.Errorf("wrong result. got=%s", val.Inspect())
+	}
+}
+
+func TestRunQuit(t *testing.T) {
+	tests := []string{"q", ""}
+
+	for _, input := range tests {
+		var out bytes.Buffer
+		_, err := Run("main.mk", program, object.NewEnvironment(), nil, strings.NewReader(input), &out)
+		if err == nil || err.Error() != "program stopped" {
+			t.Errorf("expected the program to stop for %q. got=%v", input, err)
+		}
+	}
+
+	// Runaway recursion fails with a traceback, without a stack overflow.
+	_, err := Run("main.mk", "let go = fn(n) { go(n + 1) + 1 };\ngo(0);", object.NewEnvironment(), nil, strings.NewReader("c"), &bytes.Buffer{})
+	if err == nil || !strings.HasPrefix(err.Error(), "maximum call depth exceeded\n  at go (main.mk:1:18)") {
+		t.Errorf("expected the call depth to be exceeded. got=%.80v", err)
+	}
+
+	_, err = Run("main.mk", "let = 1;", object.NewEnvironment(), nil, strings.NewReader(""), &bytes.Buffer{})
+	if err == nil || err.Error() != `main.mk:1:5: Expected="IDENT", got="="` {
+		t.Errorf("expected a parse error. got=%v", err)
+	}
+}
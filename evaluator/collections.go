@@ -0,0 +1,293 @@
+package evaluator
+
+import (
+	"inter/object"
+	"sort"
+)
+
+// collectionBuiltins returns the higher-order functions on arrays. They
+// call back into the functions they are given from Go, which is faster and
+// uses less stack than the same functions written as recursive scripts.
+// Each element counts as an evaluation step, and errors returned by the
+// callbacks stop the iteration.
+func collectionBuiltins() map[string]*Native {
+	return map[string]*Native{
+		"map": {Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunction("map", args)
+			if err != nil {
+				return err
+			}
+			res := make([]object.Object, len(arr.Elements))
+			for i, el := range arr.Elements {
+				val := in.callback(fn, el)
+				if isError(val) {
+					return val
+				}
+				res[i] = val
+			}
+			return &object.Array{Elements: res}
+		}},
+		"filter": {Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunction("filter", args)
+			if err != nil {
+				return err
+			}
+			res := []object.Object{}
+			for _, el := range arr.Elements {
+				val := in.callback(fn, el)
+				if isError(val) {
+					return val
+				}
+				if isTruthy(val) {
+					res = append(res, el)
+				}
+			}
+			return &object.Array{Elements: res}
+		}},
+		"reduce": {Fn: reduce},
+		"each": {Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			arr, fn, err := arrayAndFunction("each", args)
+			if err != nil {
+				return err
+			}
+			for _, el := range arr.Elements {
+				if val := in.callback(fn, el); isError(val) {
+					return val
+				}
+			}
+			return NULL
+		}},
+		"any": {Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			return in.quantify("any", args, true)
+		}},
+		"all": {Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			return in.quantify("all", args, false)
+		}},
+		"sort_by": {Fn: sortBy},
+		"zip":     {Fn: zip},
+		"range":   {Fn: rangeArray},
+	}
+}
+
+// callback calls fn with args for a builtin, counting an evaluation step so
+// that limits and cancellation apply to loops calling builtins.
+func (in *Interpreter) callback(fn object.Object, args ...object.Object) object.Object {
+	if err := in.step(); err != nil {
+		return err
+	}
+	return in.applyFunction(nil, fn, args)
+}
+
+func isFunction(obj object.Object) bool {
+	return obj.Type() == object.FUNCTION_OBJ || obj.Type() == object.BUILTIN_OBJ
+}
+
+// arrayAndFunction checks the arguments of the builtin name, an array and
+// a function.
+func arrayAndFunction(name string, args []object.Object) (*object.Array, object.Object, *object.Error) {
+	if err := arity(args, 2); err != nil {
+		return nil, nil, err
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return nil, nil, newError(object.KindType, "argument 1 to `%s` must be ARRAY, got %s", name, args[0].Type())
+	}
+	if !isFunction(args[1]) {
+		return nil, nil, newError(object.KindType, "argument 2 to `%s` must be FUNCTION, got %s", name, args[1].Type())
+	}
+	return arr, args[1], nil
+}
+
+// reduce implements reduce(array, fn, initial), folding the elements with
+// fn(accumulator, element). Without an initial value, the first element is
+// used.
+func reduce(in *Interpreter, args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=2 or 3", len(args))
+	}
+	arr, fn, err := arrayAndFunction("reduce", args[:2])
+	if err != nil {
+		return err
+	}
+
+	elements := arr.Elements
+	var acc object.Object
+	if len(args) == 3 {
+		acc = args[2]
+	} else {
+		if len(elements) == 0 {
+			return newError(object.KindArgument, "reduce of empty array with no initial value")
+		}
+		acc, elements = elements[0], elements[1:]
+	}
+
+	for _, el := range elements {
+		acc = in.callback(fn, acc, el)
+		if isError(acc) {
+			return acc
+		}
+	}
+	return acc
+}
+
+// quantify implements any and all, stopping at the first element for which
+// fn returns stop.
+func (in *Interpreter) quantify(name string, args []object.Object, stop bool) object.Object {
+	arr, fn, err := arrayAndFunction(name, args)
+	if err != nil {
+		return err
+	}
+	for _, el := range arr.Elements {
+		val := in.callback(fn, el)
+		if isError(val) {
+			return val
+		}
+		if isTruthy(val) == stop {
+			return getBool(stop)
+		}
+	}
+	return getBool(!stop)
+}
+
+// sortBy implements sort_by(array, fn), a stable sort of the elements by
+// the keys fn returns for them. Keys are all numbers or all strings.
+func sortBy(in *Interpreter, args ...object.Object) object.Object {
+	arr, fn, err := arrayAndFunction("sort_by", args)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]object.Object, len(arr.Elements))
+	for i, el := range arr.Elements {
+		key := in.callback(fn, el)
+		if isError(key) {
+			return key
+		}
+		if _, number := toFloat(key); !number && key.Type() != object.STRING_OBJ {
+			return newError(object.KindType, "sort_by keys must be numbers or strings, got %s", key.Type())
+		}
+		if i > 0 && (key.Type() == object.STRING_OBJ) != (keys[0].Type() == object.STRING_OBJ) {
+			return newError(object.KindType, "cannot compare sort_by keys %s and %s", keys[0].Type(), key.Type())
+		}
+		keys[i] = key
+	}
+
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(keys[order[i]], keys[order[j]])
+	})
+
+	res := make([]object.Object, len(order))
+	for i, idx := range order {
+		res[i] = arr.Elements[idx]
+	}
+	return &object.Array{Elements: res}
+}
+
+// less orders two strings, or two numbers.
+func less(a, b object.Object) bool {
+	if a, ok := a.(*object.String); ok {
+		return a.Value < b.(*object.String).Value
+	}
+	if a, ok := a.(*object.Integer); ok {
+		if b, ok := b.(*object.Integer); ok {
+			return a.Value < b.Value
+		}
+	}
+	x, _ := toFloat(a)
+	y, _ := toFloat(b)
+	return x < y
+}
+
+// zip implements zip(arrays...), the array of the tuples of the elements
+// at the same index, as long as the shortest array.
+func zip(in *Interpreter, args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(object.KindArgument, "wrong number of arguments. got=0, want at least 1")
+	}
+
+	n := -1
+	for i, arg := range args {
+		arr, ok := arg.(*object.Array)
+		if !ok {
+			return newError(object.KindType, "argument %d to `zip` must be ARRAY, got %s", i+1, arg.Type())
+		}
+		if n < 0 || len(arr.Elements) < n {
+			n = len(arr.Elements)
+		}
+	}
+
+	res := make([]object.Object, n)
+	for i := range res {
+		tuple := make([]object.Object, len(args))
+		for j, arg := range args {
+			tuple[j] = arg.(*object.Array).Elements[i]
+		}
+		res[i] = in.track(&object.Array{Elements: tuple})
+		if isError(res[i]) {
+			return res[i]
+		}
+	}
+	return &object.Array{Elements: res}
+}
+
+// maxRange bounds the length of the arrays created by range, keeping a
+// range to a few tens of megabytes when no memory limit is set.
+const maxRange = 1 << 20
+
+// rangeArray implements range(end), range(start, end) and range(start,
+// end, step), the integers from start up to end excluded.
+func rangeArray(in *Interpreter, args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 3 {
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=1 to 3", len(args))
+	}
+	bounds := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(object.KindType, "argument %d to `range` must be INTEGER, got %s", i+1, arg.Type())
+		}
+		bounds[i] = n.Value
+	}
+
+	start, end, step := int64(0), bounds[0], int64(1)
+	if len(bounds) > 1 {
+		start, end = bounds[0], bounds[1]
+	}
+	if len(bounds) > 2 {
+		step = bounds[2]
+	}
+	if step == 0 {
+		return newError(object.KindArgument, "range step must not be zero")
+	}
+
+	// The span is computed on unsigned integers, which cannot overflow.
+	span, stride := uint64(0), uint64(1)
+	switch {
+	case step > 0 && end > start:
+		span, stride = uint64(end)-uint64(start), uint64(step)
+	case step < 0 && end < start:
+		span, stride = uint64(start)-uint64(end), -uint64(step)
+	}
+	n := span / stride
+	if span%stride != 0 {
+		n++
+	}
+	if n > maxRange {
+		return newError(object.KindArgument, "range of %d integers is too large", n)
+	}
+	// Account for the integers before creating them, so that a large range
+	// fails on the memory limit before using the memory.
+	if err := in.alloc(objectSize * int64(n)); err != nil {
+		return err
+	}
+
+	res := make([]object.Object, n)
+	for i := range res {
+		res[i] = &object.Integer{Value: start + int64(i)*step}
+	}
+	return &object.Array{Elements: res}
+}
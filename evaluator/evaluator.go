@@ -7,43 +7,61 @@ import (
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL  = object.NULL
+	TRUE  = object.TRUE
+	FALSE = object.FALSE
 )
 
 func isError(obj object.Object) bool {
 	return obj != nil && obj.Type() == object.ERROR_OBJ
 }
 
-func Eval(node ast.Node, env *object.Environment) object.Object {
+func (in *Interpreter) Eval(node ast.Node, env *object.Environment) object.Object {
+	res := in.eval(node, env)
+	if err, ok := res.(*object.Error); ok && err.Stack == nil {
+		err.Stack = in.stackTrace(node)
+	}
+	return res
+}
+
+func (in *Interpreter) eval(node ast.Node, env *object.Environment) object.Object {
+	if err := in.step(); err != nil {
+		return err
+	}
+	if in.sampler != nil {
+		in.sample(node)
+	}
+
 	switch x := node.(type) {
 	case *ast.Program:
-		return evalProgram(x, env)
+		return in.evalProgram(x, env)
 
 	case *ast.PrefixExpression:
-		right := Eval(x.Right, env)
+		right := in.Eval(x.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(x.Operator, right)
+		return in.track(evalPrefixExpression(x.Operator, right))
 
 	case *ast.InfixExpression:
-		left := Eval(x.Left, env)
+		left := in.Eval(x.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(x.Right, env)
+		right := in.Eval(x.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(x.Operator, left, right)
+		return in.track(evalInfixExpression(x.Operator, left, right))
 
 	case *ast.ExpressionStatement:
-		return Eval(x.Expression, env)
+		return in.Eval(x.Expression, env)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: x.Value}
+		return in.track(&object.Integer{Value: x.Value})
+
+	case *ast.FloatLiteral:
+		return in.track(&object.Float{Value: x.Value})
 
 	case *ast.BooleanLiteral:
 		if x.Value {
@@ -52,75 +70,326 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return FALSE
 
 	case *ast.IfExpression:
-		cond := Eval(x.Condition, env)
+		cond := in.Eval(x.Condition, env)
 		if isError(cond) {
 			return cond
 		}
-		return evalIfExpression(cond, x.Body, x.ElseBody, env)
+		if h, ok := in.hook.(BranchHook); ok {
+			h.Branch(in, x, isTruthy(cond))
+		}
+		return in.evalIfExpression(cond, x.Body, x.ElseBody, env)
 
 	case *ast.BlockStatement:
-		return evalBlockStatements(x, env)
+		return in.evalBlockStatements(x, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(x.ReturnValue, env)
+		val := in.Eval(x.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
+	case *ast.ThrowStatement:
+		val := in.Eval(x.Value, env)
+		if isError(val) {
+			return val
+		}
+		return throw(val)
+
+	case *ast.TryExpression:
+		return in.evalTryExpression(x, env)
+
+	case *ast.ImportStatement:
+		return in.evalImportStatement(x, env)
+
 	case *ast.LetStatement:
-		val := Eval(x.Value, env)
+		val := in.Eval(x.Value, env)
 		if isError(val) {
 			return val
 		}
+		if err := in.alloc(bindingSize); err != nil {
+			return err
+		}
 		env.Set(x.Name.Value, val)
 		return val
 
 	case *ast.Identifier:
-		return evalIdentifier(x, env)
+		return in.evalIdentifier(x, env)
 
 	case *ast.FunctionLiteral:
-		params := x.FunctionParameters
-		body := x.FunctionBody
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return in.track(&object.Function{
+			Name:       x.Name,
+			Parameters: x.FunctionParameters,
+			Defaults:   x.Defaults,
+			Rest:       x.Rest,
+			Body:       x.FunctionBody,
+			Env:        env,
+		})
 
 	case *ast.CallExpression:
-		fun := Eval(x.Function, env)
+		fun := in.Eval(x.Function, env)
 		if isError(fun) {
 			return fun
 		}
 
-		args := evalExpressions(x.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
+		args, named, err := in.evalArguments(x.Arguments, env)
+		if err != nil {
+			return err
+		}
+
+		script, ok := fun.(*object.Function)
+		switch {
+		case !ok && len(named) > 0:
+			return newError(object.KindArgument, "named arguments passed to %s", fun.Type())
+		case !ok:
+			return in.applyFunction(x, fun, args)
+		case x.Tail:
+			return &tailCall{call: x, fn: script, args: args, named: named}
+		default:
+			return in.applyScriptFunction(x, script, args, named)
+		}
+
+	case *ast.StringLiteral:
+		return in.track(&object.String{Value: x.Value})
+
+	case *ast.ArrayLiteral:
+		elements := in.evalExpressions(x.Elements, env)
+		if len(elements) == 1 && isError(elements[0]) {
+			return elements[0]
+		}
+		return in.track(&object.Array{Elements: elements})
+
+	case *ast.IndexExpression:
+		left := in.Eval(x.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := in.Eval(x.Index, env)
+		if isError(index) {
+			return index
+		}
+		return evalIndexExpression(left, index)
+
+	case *ast.MemberExpression:
+		left := in.Eval(x.Left, env)
+		if isError(left) {
+			return left
 		}
+		return in.evalMemberExpression(left, x.Property.Value)
 
-		funCast := fun.(*object.Function)
-		return applyFunction(funCast, args)
+	case *ast.HashLiteral:
+		return in.evalHashLiteral(x, env)
 	}
 
 	return nil
 }
 
-func applyFunction(fun *object.Function, args []object.Object) object.Object {
+// Call calls fn with args from the host, as if from a call expression
+// without a call site. Script functions run in their own frame.
+func (in *Interpreter) Call(fn object.Object, args ...object.Object) object.Object {
+	return in.applyFunction(nil, fn, args)
+}
+
+// applyFunction calls fn with args. call is the call site, if any.
+func (in *Interpreter) applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object) object.Object {
+	switch fun := fn.(type) {
+	case *object.Function:
+		return in.applyScriptFunction(call, fun, args, nil)
+	case *object.Builtin:
+		return in.track(fun.Fn(args...))
+	case *Native:
+		return in.track(fun.Fn(in, args...))
+	default:
+		return newError(object.KindType, "not a function: %s", fn.Type())
+	}
+}
+
+// applyScriptFunction calls fun in a new frame. Calls in tail position in
+// its body reuse the frame: the body returns them as a tailCall, and they
+// are made in the loop below, so that tail recursion runs in constant Go
+// stack space and call depth. The replaced functions are not part of the
+// stack traces of errors.
+func (in *Interpreter) applyScriptFunction(call *ast.CallExpression, fun *object.Function, args []object.Object, named []namedArgument) object.Object {
+	params, rest, err := bindArguments(fun, args, named)
+	if err != nil {
+		return err
+	}
+
+	if err := in.enter(); err != nil {
+		return err
+	}
+	defer in.leave()
+
+	frame := &Frame{Function: fun, Call: call}
+	in.pushFrame(frame)
+	defer in.popFrame()
+
+	for {
+		res := in.evalFunctionBody(frame, fun, params, rest)
+		tc, ok := res.(*tailCall)
+		if !ok {
+			return res
+		}
+
+		fun = tc.fn
+		params, rest, err = bindArguments(fun, tc.args, tc.named)
+		if err != nil {
+			// Like the errors of other calls, it happened at the call site,
+			// in the function still on the frame.
+			err.Stack = in.stackTrace(tc.call)
+			return err
+		}
+		frame.TailCalls++
+	}
+}
+
+// evalFunctionBody binds the parameters of fun in a new environment, which
+// becomes the one of frame, and evaluates the body of fun in it.
+func (in *Interpreter) evalFunctionBody(frame *Frame, fun *object.Function, params, rest []object.Object) object.Object {
+	bindings := len(params)
+	if fun.Rest != nil {
+		bindings++
+	}
+	if err := in.alloc(envSize + bindingSize*int64(bindings)); err != nil {
+		return err
+	}
+
 	newEnv := object.NewEnclosedEnv(fun.Env)
-	for paramId, param := range fun.Parameters {
-		newEnv.Set(param.Value, args[paramId])
+	for i, param := range fun.Parameters {
+		if params[i] != nil {
+			newEnv.Set(param.Value, params[i])
+		}
 	}
+	if fun.Rest != nil {
+		arr := in.track(&object.Array{Elements: rest})
+		if isError(arr) {
+			return arr
+		}
+		newEnv.Set(fun.Rest.Value, arr)
+	}
+	frame.Function, frame.Env = fun, newEnv
 
-	evaluated := Eval(fun.Body, newEnv)
-	if evaluated.Type() == object.RETURNVALUE_OBJ {
+	// Defaults are evaluated on each call, from left to right, so they can
+	// refer to the parameters before them.
+	for i, param := range fun.Parameters {
+		if params[i] != nil {
+			continue
+		}
+		val := in.Eval(fun.Defaults[i], newEnv)
+		if isError(val) {
+			return val
+		}
+		newEnv.Set(param.Value, val)
+	}
+
+	evaluated := in.Eval(fun.Body, newEnv)
+	if evaluated != nil && evaluated.Type() == object.RETURNVALUE_OBJ {
 		unwrapped := evaluated.(*object.ReturnValue)
 		return unwrapped.Value
 	}
 	return evaluated
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+// namedArgument is the value of an argument name: value.
+type namedArgument struct {
+	name  string
+	value object.Object
+}
+
+// bindArguments matches the arguments of a call to the parameters of fun.
+// It returns the value of each parameter, nil for the ones left to their
+// default, and the extra positional arguments for the rest parameter.
+func bindArguments(fun *object.Function, args []object.Object, named []namedArgument) ([]object.Object, []object.Object, *object.Error) {
+	required := 0
+	for i := range fun.Parameters {
+		if i >= len(fun.Defaults) || fun.Defaults[i] == nil {
+			required++
+		}
+	}
+
+	n := len(fun.Parameters)
+	if len(args) > n && fun.Rest == nil || len(args)+len(named) < required {
+		want := fmt.Sprint(required)
+		switch {
+		case fun.Rest != nil:
+			want = "at least " + want
+		case required < n:
+			want = fmt.Sprintf("%d to %d", required, n)
+		}
+		return nil, nil, newError(object.KindArgument, "wrong number of arguments to %s. got=%d, want=%s", fun.Signature(), len(args)+len(named), want)
+	}
+
+	params := make([]object.Object, n)
+	rest := []object.Object{}
+	if len(args) > n {
+		copy(params, args[:n])
+		rest = append(rest, args[n:]...)
+	} else {
+		copy(params, args)
+	}
+
+	for _, arg := range named {
+		i := 0
+		for i < n && fun.Parameters[i].Value != arg.name {
+			i++
+		}
+		if i == n {
+			return nil, nil, newError(object.KindArgument, "unknown argument %s in call to %s", arg.name, fun.Signature())
+		}
+		if params[i] != nil {
+			return nil, nil, newError(object.KindArgument, "argument %s given twice in call to %s", arg.name, fun.Signature())
+		}
+		params[i] = arg.value
+	}
+
+	for i, param := range fun.Parameters {
+		if params[i] == nil && (i >= len(fun.Defaults) || fun.Defaults[i] == nil) {
+			return nil, nil, newError(object.KindArgument, "missing argument %s in call to %s", param.Value, fun.Signature())
+		}
+	}
+	return params, rest, nil
+}
+
+// evalArguments evaluates the arguments of a call, expanding the arrays
+// passed with ...xs into positional arguments.
+func (in *Interpreter) evalArguments(exps []ast.Expression, env *object.Environment) ([]object.Object, []namedArgument, object.Object) {
+	args := []object.Object{}
+	var named []namedArgument
+
+	for _, exp := range exps {
+		switch exp := exp.(type) {
+		case *ast.SpreadExpression:
+			val := in.Eval(exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			arr, ok := val.(*object.Array)
+			if !ok {
+				return nil, nil, newError(object.KindType, "cannot spread %s, want ARRAY", val.Type())
+			}
+			args = append(args, arr.Elements...)
+		case *ast.NamedArgument:
+			val := in.Eval(exp.Value, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			named = append(named, namedArgument{name: exp.Name.Value, value: val})
+		default:
+			val := in.Eval(exp, env)
+			if isError(val) {
+				return nil, nil, val
+			}
+			args = append(args, val)
+		}
+	}
+	return args, named, nil
+}
+
+func (in *Interpreter) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	res := []object.Object{}
 
 	for _, exp := range exps {
-		val := Eval(exp, env)
+		val := in.Eval(exp, env)
 		if isError(val) {
 			return []object.Object{val}
 		}
@@ -130,22 +399,22 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return res
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (in *Interpreter) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	val, ok := env.Get(node.Value)
 	if !ok {
-		return newError("identifier not found: " + node.Value)
+		return newError(object.KindName, "identifier not found: "+node.Value)
 	}
 
 	return val
 }
 
-func evalIfExpression(cond object.Object, body *ast.BlockStatement, elseBody *ast.BlockStatement, env *object.Environment) object.Object {
+func (in *Interpreter) evalIfExpression(cond object.Object, body *ast.BlockStatement, elseBody *ast.BlockStatement, env *object.Environment) object.Object {
 	if isTruthy(cond) {
-		return Eval(body, env)
+		return in.Eval(body, env)
 	}
 
 	if elseBody != nil {
-		return Eval(elseBody, env)
+		return in.Eval(elseBody, env)
 	}
 
 	return NULL
@@ -171,7 +440,20 @@ func evalInfixExpressionForBooleans(operator string, left *object.Boolean, right
 	case "!=":
 		return getBool(left.Value != right.Value)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func evalInfixExpressionForStrings(operator string, left *object.String, right *object.String) object.Object {
+	switch operator {
+	case "+":
+		return &object.String{Value: left.Value + right.Value}
+	case "==":
+		return getBool(left.Value == right.Value)
+	case "!=":
+		return getBool(left.Value != right.Value)
+	default:
+		return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -187,6 +469,9 @@ func evalInfixExpressionForInteger(operator string, left *object.Integer, right
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(object.KindZeroDivision, "division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "==":
 		return getBool(left.Value == right.Value)
@@ -197,7 +482,50 @@ func evalInfixExpressionForInteger(operator string, left *object.Integer, right
 	case "<":
 		return getBool(leftVal < rightVal)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalInfixExpressionForFloats evaluates operators on two numbers of which
+// at least one is a float, the other one being converted to a float.
+func evalInfixExpressionForFloats(operator string, left object.Object, right object.Object) object.Object {
+	leftVal, _ := toFloat(left)
+	rightVal, _ := toFloat(right)
+
+	switch operator {
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		if rightVal == 0 {
+			return newError(object.KindZeroDivision, "division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case "==":
+		return getBool(leftVal == rightVal)
+	case "!=":
+		return getBool(leftVal != rightVal)
+	case ">":
+		return getBool(leftVal > rightVal)
+	case "<":
+		return getBool(leftVal < rightVal)
+	default:
+		return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// toFloat returns the value of an integer or a float as a float.
+func toFloat(obj object.Object) (float64, bool) {
+	switch n := obj.(type) {
+	case *object.Integer:
+		return float64(n.Value), true
+	case *object.Float:
+		return n.Value, true
+	default:
+		return 0, false
 	}
 }
 
@@ -206,15 +534,29 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return evalInfixExpressionForInteger(operator, left.(*object.Integer), right.(*object.Integer))
 	}
 
+	_, leftNumber := toFloat(left)
+	_, rightNumber := toFloat(right)
+	if leftNumber && rightNumber {
+		return evalInfixExpressionForFloats(operator, left, right)
+	}
+
 	if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
 		return evalInfixExpressionForBooleans(operator, left.(*object.Boolean), right.(*object.Boolean))
 	}
 
+	if left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ {
+		return evalInfixExpressionForStrings(operator, left.(*object.String), right.(*object.String))
+	}
+
+	if res, ok := evalInfixExpressionForTimes(operator, left, right); ok {
+		return res
+	}
+
 	if left.Type() != right.Type() {
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.KindType, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
 
-	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
 func getBool(val bool) *object.Boolean {
@@ -231,17 +573,19 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 	case "-":
 		return evalMinusOperator(right)
 	default:
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.KindType, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
 func evalMinusOperator(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.KindType, "unknown operator: -%s", right.Type())
 	}
-
-	val := right.(*object.Integer).Value
-	return &object.Integer{Value: -val}
 }
 
 func evalBangOperator(right object.Object) object.Object {
@@ -257,11 +601,166 @@ func evalBangOperator(right object.Object) object.Object {
 	}
 }
 
-func evalBlockStatements(bs *ast.BlockStatement, env *object.Environment) object.Object {
-	var res object.Object
+func evalIndexExpression(left object.Object, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elements := left.(*object.Array).Elements
+		idx := index.(*object.Integer).Value
+		if idx < 0 || idx >= int64(len(elements)) {
+			return NULL
+		}
+		return elements[idx]
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.KindType, "unusable as hash key: %s", index.Type())
+		}
+		pair, ok := left.(*object.Hash).Pairs[key.HashKey()]
+		if !ok {
+			return NULL
+		}
+		return pair.Value
+	default:
+		return newError(object.KindType, "index operator not supported: %s", left.Type())
+	}
+}
+
+// throw turns val into an error aborting the evaluation. Thrown error
+// values keep the stack of their first throw.
+func throw(val object.Object) object.Object {
+	switch val := val.(type) {
+	case *object.ErrorValue:
+		if val.Error.Stack != nil {
+			return val.Error
+		}
+		err := *val.Error
+		return &err
+	case *object.String:
+		return &object.Error{Message: val.Value, Kind: object.KindError}
+	default:
+		return newError(object.KindType, "cannot throw %s", val.Type())
+	}
+}
+
+// evalTryExpression catches the errors of the body, except the ones raised
+// by limits and cancellation, which also skip the finally block. The catch
+// block runs in its own environment, so its parameter does not outlive it.
+func (in *Interpreter) evalTryExpression(te *ast.TryExpression, env *object.Environment) object.Object {
+	res := in.Eval(te.Body, env)
+
+	if err, ok := res.(*object.Error); ok && te.CatchBody != nil && catchable(err) {
+		if err := in.alloc(envSize + bindingSize); err != nil {
+			return err
+		}
+		catchEnv := object.NewEnclosedEnv(env)
+		catchEnv.Set(te.CatchParam.Value, in.track(&object.ErrorValue{Error: err}))
+		res = in.Eval(te.CatchBody, catchEnv)
+	}
+
+	if te.FinallyBody != nil {
+		if err, ok := res.(*object.Error); ok && !catchable(err) {
+			return res
+		}
+
+		// The result of finally is dropped, unless it returns or fails.
+		fin := in.Eval(te.FinallyBody, env)
+		if isError(fin) || fin.Type() == object.RETURNVALUE_OBJ {
+			return fin
+		}
+	}
+
+	return res
+}
+
+func (in *Interpreter) evalMemberExpression(left object.Object, name string) object.Object {
+	switch left := left.(type) {
+	case *object.ErrorValue:
+		return in.evalErrorField(left.Error, name)
+	case *object.Time:
+		return in.evalTimeField(left.Value, name)
+	case *object.Duration:
+		return in.evalDurationField(left.Value, name)
+	case *object.Hash:
+		pair, ok := left.Pairs[(&object.String{Value: name}).HashKey()]
+		if !ok {
+			return NULL
+		}
+		return pair.Value
+	case *object.Module:
+		val, ok := left.Member(name)
+		if !ok {
+			return newError(object.KindName, "module %s has no member %s", left.Name, name)
+		}
+		return val
+	default:
+		return newError(object.KindType, "member access not supported: %s", left.Type())
+	}
+}
+
+func (in *Interpreter) evalErrorField(err *object.Error, name string) object.Object {
+	switch name {
+	case "message":
+		return in.track(&object.String{Value: err.Message})
+	case "kind":
+		return in.track(&object.String{Value: err.KindName()})
+	case "stack":
+		frames := make([]object.Object, len(err.Stack))
+		for i, f := range err.Stack {
+			frames[i] = in.track(stackFrameHash(f))
+		}
+		return in.track(&object.Array{Elements: frames})
+	default:
+		return newError(object.KindName, "unknown field %s of ERROR_VALUE", name)
+	}
+}
+
+func stackFrameHash(f object.StackFrame) *object.Hash {
+	fields := map[string]object.Object{
+		"function": &object.String{Value: f.Function},
+		"file":     &object.String{Value: f.File},
+		"line":     &object.Integer{Value: int64(f.Line)},
+		"column":   &object.Integer{Value: int64(f.Column)},
+	}
+
+	pairs := make(map[object.HashKey]object.HashPair)
+	for name, val := range fields {
+		key := &object.String{Value: name}
+		pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+	}
+	return &object.Hash{Pairs: pairs}
+}
+
+func (in *Interpreter) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	pairs := make(map[object.HashKey]object.HashPair)
+
+	for _, keyNode := range node.Keys {
+		key := in.Eval(keyNode, env)
+		if isError(key) {
+			return key
+		}
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.KindType, "unusable as hash key: %s", key.Type())
+		}
+
+		value := in.Eval(node.Pairs[keyNode], env)
+		if isError(value) {
+			return value
+		}
+
+		pairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return in.track(&object.Hash{Pairs: pairs})
+}
+
+func (in *Interpreter) evalBlockStatements(bs *ast.BlockStatement, env *object.Environment) object.Object {
+	var res object.Object = NULL
 
 	for _, st := range bs.Statements {
-		res = Eval(st, env)
+		in.beforeStatement(st, env)
+		res = in.Eval(st, env)
 		if res.Type() == object.RETURNVALUE_OBJ {
 			return res
 		}
@@ -274,11 +773,17 @@ func evalBlockStatements(bs *ast.BlockStatement, env *object.Environment) object
 	return res
 }
 
-func evalProgram(prog *ast.Program, env *object.Environment) object.Object {
+func (in *Interpreter) evalProgram(prog *ast.Program, env *object.Environment) object.Object {
 	var res object.Object
 
+	if len(in.frames) == 0 {
+		in.pushFrame(&Frame{Env: env})
+		defer in.popFrame()
+	}
+
 	for _, st := range prog.Statements {
-		res = Eval(st, env)
+		in.beforeStatement(st, env)
+		res = in.Eval(st, env)
 		if res.Type() == object.RETURNVALUE_OBJ {
 			returnVal := res.(*object.ReturnValue)
 			return returnVal.Value
@@ -292,6 +797,6 @@ func evalProgram(prog *ast.Program, env *object.Environment) object.Object {
 	return res
 }
 
-func newError(fmtStr string, args ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(fmtStr, args...)}
+func newError(kind string, fmtStr string, args ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(fmtStr, args...), Kind: kind}
 }
@@ -1,10 +1,22 @@
 package evaluator
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"inter/ast"
 	"inter/lexer"
 	"inter/object"
 	"inter/parser"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -55,6 +67,14 @@ func TestErrorHandling(t *testing.T) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"10 / 0",
+			"division by zero",
+		},
+		{
+			"let add = fn(x, y) { x + y }; add(1)",
+			"wrong number of arguments to add(x, y). got=1, want=2",
+		},
 		{
 			`
 if (10 > 1) {
@@ -104,6 +124,51 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 2) { [a, b] }; f(1)", "[1, 2]"},
+		{"let f = fn(a, b = 2) { [a, b] }; f(1, 3)", "[1, 3]"},
+		{"let f = fn(a, b = a * 10) { [a, b] }; f(4)", "[4, 40]"},
+		{"let f = fn(a, ...rest) { [a, rest] }; f(1)", "[1, []]"},
+		{"let f = fn(a, ...rest) { [a, rest] }; f(1, 2, 3)", "[1, [2, 3]]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 5)", "[1, 3, [5]]"},
+		{"let f = fn(a, b, c) { [a, b, c] }; let xs = [2, 3]; f(1, ...xs)", "[1, 2, 3]"},
+		{"let f = fn(...xs) { xs }; f(...[], 1, ...[2, 3])", "[1, 2, 3]"},
+		{"let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 4)", "[1, 2, 4]"},
+		{"let f = fn(a, b = 2) { [a, b] }; f(b: 5, a: 6)", "[6, 5]"},
+		{"let f = fn(a = 1) { a }; let g = fn() { f() }; [g(), g()]", "[1, 1]"},
+
+		{"let f = fn(a, b = 2) { a }; f()", "wrong number of arguments to f(a, b = 2). got=0, want=1 to 2"},
+		{"let f = fn(a, b = 2) { a }; f(1, 2, 3)", "wrong number of arguments to f(a, b = 2). got=3, want=1 to 2"},
+		{"let f = fn(a, ...rest) { a }; f()", "wrong number of arguments to f(a, ...rest). got=0, want=at least 1"},
+		{"let f = fn(a, b) { a }; f(b: 1, c: 2)", "unknown argument c in call to f(a, b)"},
+		{"let f = fn(a, b) { a }; f(b: 1, x: 2)", "unknown argument x in call to f(a, b)"},
+		{"let f = fn(a, b) { a }; f(1, a: 2)", "argument a given twice in call to f(a, b)"},
+		{"let f = fn(a, b, c = 3) { a }; f(1, c: 2)", "missing argument b in call to f(a, b, c = 3)"},
+		{"let f = fn(a = x) { a }; f()", "identifier not found: x"},
+		{"fn(a) { a }(...1)", "cannot spread INTEGER, want ARRAY"},
+		{"let f = fn(...rest) { rest }; f", "fn(...rest) {\nrest\n}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestNamedArgumentsToBuiltins(t *testing.T) {
+	env := NewSandbox().NewEnvironment()
+	evaluated := Eval(parser.New(lexer.New(`strings.upper(s: "a")`)).ParseProgram(), env)
+	if expected := "named arguments passed to BUILTIN"; evaluated.Inspect() != expected {
+		t.Errorf("wrong result. expected=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -189,6 +254,459 @@ func TestEvalBoolExpressions(t *testing.T) {
 	}
 }
 
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Hello World!"`, "Hello World!"},
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" - "b"`, "unknown operator: STRING - STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBoolObject(t, evaluated, expected)
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			testStringObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestArrayIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{"a" + "b": 1 + 1}["ab"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if integer, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else if evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestBuiltinApplication(t *testing.T) {
+	add, err := object.NewBuiltin(func(a, b int) int { return a + b })
+	if err != nil {
+		t.Fatalf("NewBuiltin failed: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"add(1, 2)", 3},
+		{"let twice = fn(f, x) { f(x, x) }; twice(add, 4)", 8},
+		{`add(1, "2")`, "argument 2: cannot convert STRING to int"},
+		{"add(1)", "wrong number of arguments. got=1, want=2"},
+		{"1(2)", "not a function: INTEGER"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := parser.New(l)
+		env := object.NewEnvironment()
+		env.Set("add", add)
+
+		evaluated := Eval(p.ParseProgram(), env)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEmptyBlocks(t *testing.T) {
+	tests := []string{
+		"if (true) {}",
+		"fn() {}()",
+	}
+
+	for _, input := range tests {
+		if evaluated := testEval(input); evaluated != NULL {
+			t.Errorf("object is not NULL. got=%T (%+v)", evaluated, evaluated)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	expired, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   Limits
+		expected error
+	}{
+		{"let f = fn() { 1 + f() }; f()", context.Background(), Limits{MaxDepth: 100}, ErrMaxDepth},
+		{"let f = fn() { f() }; f()", context.Background(), Limits{MaxSteps: 10000}, ErrMaxSteps},
+		{"let f = fn(n) { if (n > 0) { f(n - 1) } }; f(1000)", context.Background(), Limits{MaxSteps: 500}, ErrMaxSteps},
+		{"1 + 2", expired, Limits{}, context.Canceled},
+		{`let f = fn(s, n) { if (n > 0) { f(s + s, n - 1) } else { s } }; f("ab", 30)`, context.Background(), Limits{MaxAlloc: 1 << 20}, ErrMaxAlloc},
+		{"let f = fn(a) { f([a, a, a]) }; f(1)", context.Background(), Limits{MaxAlloc: 4096}, ErrMaxAlloc},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(tt.ctx, program, object.NewEnvironment(), tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if !errors.Is(errObj.Err, tt.expected) {
+			t.Errorf("wrong error. expected=%v, got=%v (%s)", tt.expected, errObj.Err, errObj.Message)
+		}
+	}
+}
+
+func TestLimitsNotHit(t *testing.T) {
+	input := "let f = fn(n) { if (n > 0) { f(n - 1) } else { 42 } }; f(50)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	in := New(context.Background(), Limits{MaxDepth: 51, MaxSteps: 10000})
+	testIntegerObject(t, in.Eval(program, object.NewEnvironment()), 42)
+
+	if in.Steps() == 0 {
+		t.Errorf("steps were not counted")
+	}
+}
+
+func TestAllocated(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"true; false", 0},
+		{"1", objectSize},
+		{`"abcd"`, objectSize + 4},
+		{"[1, 2]", 3*objectSize + 2*elementSize},
+		{"let a = 1;", objectSize + bindingSize},
+		{"fn(x) { x }(1)", functionSize + 8 + objectSize + envSize + bindingSize},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		in := New(context.Background(), Limits{})
+		in.Eval(program, object.NewEnvironment())
+
+		if in.Allocated() != tt.expected {
+			t.Errorf("wrong allocation for %q. expected=%d, got=%d", tt.input, tt.expected, in.Allocated())
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+	tests := []struct {
+		input    string
+		allowed  []Capability
+		expected string
+	}{
+		{`puts("hi", 1)`, []Capability{CapPrint}, "Null"},
+		{`puts("hi")`, nil, `puts: missing capability "print"`},
+		{`now()`, []Capability{CapPrint}, `now: missing capability "time"`},
+		{`rand(1)`, []Capability{CapRandom}, "0"},
+		{`rand(0)`, []Capability{CapRandom}, "argument to `rand` must be positive"},
+		{`math.random()`, []Capability{CapTime}, `math.random: missing capability "random"`},
+		{`math.seed(1)`, nil, `math.seed: missing capability "random"`},
+		{`getenv("INTER_SANDBOX_TEST")`, []Capability{CapEnv}, "sandboxed"},
+		{`getenv("INTER_SANDBOX_TEST")`, []Capability{CapFS}, `getenv: missing capability "env"`},
+		{`read_file("/nonexistent")`, []Capability{CapTime}, `read_file: missing capability "fs"`},
+		{`hello()`, []Capability{"greet"}, "hello"},
+		{`hello()`, AllCapabilities, `hello: missing capability "greet"`},
+	}
+
+	t.Setenv("INTER_SANDBOX_TEST", "sandboxed")
+	hello, _ := object.NewBuiltin(func() string { return "hello" })
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		sandbox := NewSandbox(tt.allowed...)
+		sandbox.Stdout = &out
+		env := sandbox.NewEnvironment()
+		sandbox.Define(env, "hello", "greet", hello)
+
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	sandbox := NewSandbox(CapPrint)
+	sandbox.Stdout = &out
+
+	Eval(parser.New(lexer.New(`puts("a", 1 + 1, [true])`)).ParseProgram(), sandbox.NewEnvironment())
+	if out.String() != "a\n2\n[true]\n" {
+		t.Errorf("wrong output. got=%q", out.String())
+	}
+}
+
+// TestConcurrentEvaluation runs scripts sharing a prelude in parallel, run
+// it with -race to check for data races.
+func TestConcurrentEvaluation(t *testing.T) {
+	prelude := object.NewEnvironment()
+	Eval(parser.New(lexer.New(`
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let table = {"one": 1, "two": 2};
+let fs = [fn(x) { x }];
+`)).ParseProgram(), prelude)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			input := fmt.Sprintf(`let n = %d; let fib = fn(x) { x }; table["two"] + fib(n)`, i)
+			env := object.NewEnclosedEnv(prelude)
+			testIntegerObject(t, Eval(parser.New(lexer.New(input)).ParseProgram(), env), int64(2+i))
+
+			input = fmt.Sprintf("let r = fib(%d); r == fib(%d)", i%15, i%15)
+			testBoolObject(t, Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnclosedEnv(prelude)), true)
+
+			input = fmt.Sprintf("let a = fs[0]; a(%d)", i)
+			testIntegerObject(t, Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnclosedEnv(prelude)), int64(i))
+		}(i)
+	}
+	wg.Wait()
+
+	fib, _ := prelude.Get("fib")
+	if _, ok := fib.(*object.Function); !ok {
+		t.Errorf("prelude binding was modified. got=%T", fib)
+	}
+}
+
+type stackRecorder struct {
+	stacks []string
+}
+
+func (r *stackRecorder) BeforeStatement(in *Interpreter, st ast.Statement, env *object.Environment) {
+	var frames []string
+	for _, f := range in.Frames() {
+		frames = append(frames, fmt.Sprintf("%s:%d", f.Name(), ast.StartToken(f.Statement).Line))
+	}
+	r.stacks = append(r.stacks, fmt.Sprint(frames))
+}
+
+func TestHookAndFrames(t *testing.T) {
+	input := `let f = fn(x) {
+  let g = fn() { x };
+  g()
+};
+f(1);
+fn() { 2 }();`
+
+	r := &stackRecorder{}
+	in := New(context.Background(), Limits{})
+	in.SetHook(r)
+	testIntegerObject(t, in.Eval(parser.New(lexer.New(input)).ParseProgram(), object.NewEnvironment()), 2)
+
+	expected := []string{
+		"[<main>:1]",
+		"[<main>:5]",
+		"[f:2 <main>:5]",
+		"[f:3 <main>:5]",
+		"[g:2 <main>:5]",
+		"[<main>:6]",
+		"[<anonymous>:6 <main>:6]",
+	}
+	if fmt.Sprint(r.stacks) != fmt.Sprint(expected) {
+		t.Errorf("wrong stacks.\nexpected=%v\ngot=     %v", expected, r.stacks)
+	}
+
+	if in.Depth() != 0 {
+		t.Errorf("frames left on the stack. got=%d", in.Depth())
+	}
+}
+
+func TestFunctionName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn() { 1 }; f", "f"},
+		{"let f = fn() { 1 }; let g = f; g", "f"},
+		{"let fs = [fn() { 1 }]; let g = fs[0]; g", ""},
+		{"let f = if (true) { fn() { 1 } }; f", ""},
+		{"fn() { 1 }", ""},
+	}
+
+	for _, tt := range tests {
+		fn, ok := testEval(tt.input).(*object.Function)
+		if !ok {
+			t.Fatalf("object is not Function.")
+		}
+		if fn.Name != tt.expected {
+			t.Errorf("wrong name. expected=%q, got=%q", tt.expected, fn.Name)
+		}
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + true", "type mismatch: INTEGER + BOOLEAN\n  at <main> (1:1)"},
+		{
+			// The tail call to div replaces the frame of f.
+			"let div = fn(a, b) {\n  a / b\n};\nlet f = fn(x) { div(x, 0) };\n1;\nf(2)",
+			"division by zero\n  at div (2:3)\n  ... 1 tail call elided\n  at <main> (6:1)",
+		},
+		{
+			"let f = fn(x) { if (x) { return g(1, 2); } 0 };\nlet g = fn(a) { a };\nf(true)",
+			"wrong number of arguments to g(a). got=2, want=1\n  at f (1:33)\n  at <main> (3:1)",
+		},
+		{
+			"fn() { [1][0](); }()",
+			"not a function: INTEGER\n  at <anonymous> (1:8)\n  at <main> (1:1)",
+		},
+		{
+			"let h = {}; let g = fn() { h[fn() {}] }; let f = g; f()",
+			"unusable as hash key: FUNCTION\n  at g (1:28)\n  at <main> (1:53)",
+		},
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error returned for %q", tt.input)
+			continue
+		}
+		if got := err.Traceback(""); got != tt.expected {
+			t.Errorf("wrong traceback for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDeepTraceback(t *testing.T) {
+	input := "let f = fn(n) { if (n == 0) { missing } else { f(n - 1) } }; f(30)"
+	err, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned")
+	}
+
+	// The recursive calls are tail calls, reusing the first frame of f.
+	expected := "identifier not found: missing\n" +
+		"  at f (main.mk:1:31)\n" +
+		"  ... 30 tail calls elided\n" +
+		"  at <main> (main.mk:1:62)"
+	if got := err.Traceback("main.mk"); got != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", expected, got)
+	}
+
+	input = "let f = fn(n) { if (n == 0) { missing } else { f(n - 1) + 1 } }; f(30)"
+	err, ok = testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned")
+	}
+
+	if len(err.Stack) != 32 {
+		t.Fatalf("wrong stack depth. got=%d", len(err.Stack))
+	}
+
+	lines := strings.Split(err.Traceback("main.mk"), "\n")
+	start := []string{
+		"identifier not found: missing",
+		"  at f (main.mk:1:31)",
+		"  at f (main.mk:1:48)",
+	}
+	if len(lines) != 22 || fmt.Sprint(lines[:3]) != fmt.Sprint(start) {
+		t.Errorf("wrong traceback start. got=%q", lines)
+	}
+	if lines[11] != "  ... 12 more frames" || lines[21] != "  at <main> (main.mk:1:66)" {
+		t.Errorf("wrong traceback end. got=%q", lines[11:])
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let loop = fn(n, acc) { if (n == 0) { return acc; } loop(n - 1, acc + n) }; loop(100000, 0)", "5000050000"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+[even(100000), odd(100001)]`, "[true, true]"},
+		{"let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(n - 1, acc: acc + n) } }; f(100000)", "5000050000"},
+		{"let f = fn(n, ...xs) { if (n == 0) { xs } else { f(n - 1, ...xs) } }; f(100000, 1, 2)", "[1, 2]"},
+		{"let f = fn(x) { math.abs(x) }; f(-3)", "3"},
+		{`let g = fn() { throw error("boom") }; let f = fn() { try { g() } catch (e) { e.message } }; f()`, "boom"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100000)", "maximum call depth exceeded"},
+	}
+
+	for _, tt := range tests {
+		in := New(context.Background(), Limits{MaxDepth: 10})
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := in.Eval(program, NewSandbox().NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+		if in.Depth() != 0 {
+			t.Errorf("frames left on the stack for %q. got=%d", tt.input, in.Depth())
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -213,6 +731,21 @@ func testBoolObject(t *testing.T, obj object.Object, expected bool) bool {
 	return true
 }
 
+func testStringObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.String)
+	if !ok {
+		t.Errorf("object is not String. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%q, want=%q",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -227,3 +760,758 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 
 	return true
 }
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{"try { 1 / 0 } catch (e) { e }", "ZeroDivisionError: division by zero"},
+		{"try { 1 + true } catch (e) { e.kind }", "TypeError"},
+		{"try { nope } catch (e) { e.message }", "identifier not found: nope"},
+		{`try { throw "bad"; 1 } catch (e) { e }`, "Error: bad"},
+		{`try { throw error("negative", "ValueError") } catch (e) { e.kind }`, "ValueError"},
+		{"try { throw 1 } catch (e) { e }", "TypeError: cannot throw INTEGER"},
+		{"throw error(\"uncaught\")", "uncaught"},
+		{"let f = fn() { 1 / 0 }; try { f() } catch (e) { e.stack[1].function }", "<main>"},
+		{"let f = fn() { 1 / 0 }; try { f() } catch (e) { e.stack[0].function }", "f"},
+		{"let log = []; let r = try { 1 } finally { puts(2) }; r", "1"},
+		{"try { try { 1 / 0 } finally { 2 } } catch (e) { e.kind }", "ZeroDivisionError"},
+		{"try { 1 } finally { throw \"in finally\" }", "in finally"},
+		{"let f = fn() { try { return 1; } finally { 2 } }; f()", "1"},
+		{"let f = fn() { try { return 1; } finally { return 3; } }; f()", "3"},
+		{"let f = fn() { try { 1 / 0 } catch (e) { return e.kind; }; 2 }; f()", "ZeroDivisionError"},
+		{"try { 1 / 0 } catch (e) { 2 }; e", "identifier not found: e"},
+		{`let e = 5; try { throw "boom" } catch (e) { 1 }; e`, "5"},
+		{"let e = 5; try { 1 / 0 } catch (e) { let x = 2; e.kind }; [e, x]", "identifier not found: x"},
+		{"try { try { 1 / 0 } catch (e) { throw e } } catch (e) { e.stack[0].line }", "1"},
+		{`{"a": 1}.a`, "1"},
+		{`{"a": 1}.b`, "Null"},
+		{"1.a", "member access not supported: INTEGER"},
+	}
+
+	sandbox := NewSandbox(CapPrint)
+	sandbox.Stdout = &bytes.Buffer{}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := Eval(program, sandbox.NewEnvironment())
+		if err, ok := evaluated.(*object.Error); ok {
+			if err.Message != tt.expected {
+				t.Errorf("wrong error for %q. expected=%q, got=%q", tt.input, tt.expected, err.Message)
+			}
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestRethrowKeepsStack(t *testing.T) {
+	input := "let f = fn() { 1 / 0 };\nlet g = fn() { try { f() } catch (e) { throw e } };\ng()"
+	err, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned")
+	}
+
+	expected := "division by zero\n  at f (1:16)\n  at g (2:22)\n  at <main> (3:1)"
+	if got := err.Traceback(""); got != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestLimitsNotCatchable(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected error
+	}{
+		{"let f = fn() { 1 + f() }; try { f() } catch (e) { 1 }", Limits{MaxDepth: 100}, ErrMaxDepth},
+		{"let f = fn(n) { f(n + 1) }; try { f(0) } catch (e) { 1 } finally { 2 }", Limits{MaxSteps: 500}, ErrMaxSteps},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok || !errors.Is(errObj.Err, tt.expected) {
+			t.Errorf("expected %v for %q. got=%s", tt.expected, tt.input, evaluated.Inspect())
+		}
+	}
+}
+
+type sampleRecorder struct {
+	samples int
+	stacks  map[string]bool
+}
+
+func (r *sampleRecorder) Sample(stack []object.StackFrame, n int) {
+	var names []string
+	for _, f := range stack {
+		names = append(names, f.Function)
+	}
+	r.samples += n
+	r.stacks[strings.Join(names, " ")] = true
+}
+
+func TestProfile(t *testing.T) {
+	input := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(20)"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	r := &sampleRecorder{stacks: make(map[string]bool)}
+	in := New(context.Background(), Limits{})
+	stop := in.Profile(r, 100*time.Microsecond)
+	testIntegerObject(t, in.Eval(program, object.NewEnvironment()), 6765)
+	stop()
+
+	if r.samples == 0 {
+		t.Fatalf("no samples recorded")
+	}
+	for stack := range r.stacks {
+		if !strings.HasSuffix(stack, "<main>") || (stack != "<main>" && !strings.HasPrefix(stack, "fib ")) {
+			t.Errorf("unexpected stack %q", stack)
+		}
+	}
+
+	samples := r.samples
+	in.Eval(program, object.NewEnvironment())
+	if r.samples != samples {
+		t.Errorf("samples recorded after stop")
+	}
+}
+
+func writeModules(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"app/util.mk":    `puts("loading util"); import m from "mathx"; let quad = fn(x) { m.square(m.square(x)) };`,
+		"app/again.mk":   `import "./util.mk"; let quad = util.quad;`,
+		"app/a.mk":       `import "./b";`,
+		"app/b.mk":       `import "../app/a";`,
+		"app/bad.mk":     `let x = ;`,
+		"app/fails.mk":   `let f = fn() { 1 / 0 }; f();`,
+		"lib/mathx.mk":   `let square = fn(x) { x * x }; let _private = 1;`,
+		"lib/shadow.mk":  `let where = "lib";`,
+		"app/shadow.mk":  `let where = "app";`,
+		"app/early.mk":   `let a = 1; return 2; let b = 3;`,
+		"app/private.mk": `import "mathx"; mathx._private`,
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "./util"; util.quad(2)`, "16"},
+		{`import u from "./util.mk"; import "./again"; again.quad(1) + u.quad(1)`, "2"},
+		{`import "shadow"; shadow.where`, "app"},
+		{`import "mathx"; mathx`, "<module mathx>"},
+		{`import "mathx"; mathx.cube`, "module mathx has no member cube"},
+		{`import "./private"`, "module mathx has no member _private"},
+		{`import "./early"; [early.a, early.b]`, "module early has no member b"},
+		{`import "./missing"`, "module not found: " + filepath.Join(dir, "app", "missing.mk")},
+		{`import "./a"`, "import cycle: " + filepath.Join(dir, "app", "a.mk") + " -> " + filepath.Join(dir, "app", "b.mk") + " -> " + filepath.Join(dir, "app", "a.mk")},
+		{`import "./bad"`, filepath.Join(dir, "app", "bad.mk") + ":1:9: no prefix parse function for ; found"},
+		{`try { import "./missing" } catch (e) { e.kind }`, "ImportError"},
+		{`let f = fn() { import "mathx"; mathx.square(3) }; f()`, "9"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		sandbox := NewSandbox(CapPrint)
+		sandbox.Stdout = &out
+		loader := NewLoader(sandbox.NewEnvironment, filepath.Join(dir, "lib"))
+
+		env := sandbox.NewEnvironment()
+		loader.SetFile(env, filepath.Join(dir, "app", "main.mk"))
+		in := New(context.Background(), Limits{})
+		in.SetLoader(loader)
+
+		evaluated := in.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, evaluated.Inspect())
+		}
+		if strings.Count(out.String(), "loading util") > 1 {
+			t.Errorf("module evaluated more than once for %q", tt.input)
+		}
+	}
+}
+
+func TestImportTraceback(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"fails.mk": "let f = fn() {\n  1 / 0\n};\nf();",
+	})
+
+	env := object.NewEnvironment()
+	loader := NewLoader(nil)
+	loader.SetFile(env, filepath.Join(dir, "main.mk"))
+	in := New(context.Background(), Limits{})
+	in.SetLoader(loader)
+
+	err, ok := in.Eval(parser.New(lexer.New("1;\nimport \"./fails\";")).ParseProgram(), env).(*object.Error)
+	if !ok {
+		t.Fatalf("no error returned")
+	}
+
+	fails := filepath.Join(dir, "fails.mk")
+	expected := "division by zero\n" +
+		"  at f (" + fails + ":2:3)\n" +
+		"  at <module fails> (" + fails + ":4:1)\n" +
+		"  at <main> (" + filepath.Join(dir, "main.mk") + ":2:1)"
+	if got := err.Traceback("ignored"); got != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestImportOverBudget(t *testing.T) {
+	dir := writeModules(t, map[string]string{"empty.mk": ``})
+
+	loads := 0
+	loader := NewLoader(nil)
+	loader.Loaded = func(path, src string, program *ast.Program) { loads++ }
+
+	// A module failing the allocation limit is not cached, so the next
+	// interpreter sharing the loader evaluates it again.
+	for _, limits := range []Limits{{MaxAlloc: 1}, {}} {
+		env := object.NewEnvironment()
+		loader.SetFile(env, filepath.Join(dir, "main.mk"))
+		in := New(context.Background(), limits)
+		in.SetLoader(loader)
+
+		evaluated := in.Eval(parser.New(lexer.New(`import "./empty"; empty`)).ParseProgram(), env)
+		err, ok := evaluated.(*object.Error)
+		if limits.MaxAlloc > 0 && (!ok || !errors.Is(err.Err, ErrMaxAlloc)) {
+			t.Errorf("expected the allocation limit to be exceeded. got=%s", evaluated.Inspect())
+		}
+		if limits.MaxAlloc == 0 && evaluated.Inspect() != "<module empty>" {
+			t.Errorf("wrong result. got=%s", evaluated.Inspect())
+		}
+	}
+
+	if loads != 2 {
+		t.Errorf("wrong number of loads. expected=2, got=%d", loads)
+	}
+}
+
+func TestImportWithoutLoader(t *testing.T) {
+	evaluated := testEval(`import "x"`)
+	if err, ok := evaluated.(*object.Error); !ok || err.Message != "imports are not supported here" {
+		t.Errorf("wrong result. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestImportSandbox(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"root/app/main.mk": ``,
+		"root/lib.mk":      `let x = 1;`,
+		"outside.mk":       `let x = 2;`,
+	})
+
+	tests := []struct {
+		allowed  []Capability
+		input    string
+		expected string
+	}{
+		{[]Capability{CapFS}, `import "../lib"; lib.x`, "1"},
+		{[]Capability{CapFS}, `import "./missing"`, "module not found: app/missing.mk"},
+		{[]Capability{CapFS}, `import "../../outside"`, "stat ../outside.mk: path escapes the root directory"},
+		{[]Capability{CapFS}, `import "` + filepath.ToSlash(filepath.Join(dir, "outside")) + `"`, "stat " + filepath.ToSlash(filepath.Join(dir, "outside.mk")) + ": path escapes the root directory"},
+		{nil, `import "../lib"`, `import: missing capability "fs"`},
+	}
+
+	for _, tt := range tests {
+		sandbox := NewSandbox(tt.allowed...)
+		sandbox.FS = DirFS(filepath.Join(dir, "root"))
+		loader := sandbox.NewLoader()
+
+		env := sandbox.NewEnvironment()
+		loader.SetFile(env, "app/main.mk")
+		in := New(context.Background(), Limits{})
+		in.SetLoader(loader)
+
+		evaluated := in.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a,b,c", ",")`, `[a, b, c]`},
+		{`strings.join(["a", "b"], "-")`, "a-b"},
+		{`strings.trim("  hi\n")`, "hi"},
+		{`strings.trim("xxhixx", "x")`, "hi"},
+		{`strings.trim("a", "b", "c")`, "wrong number of arguments. got=3, want=1 or 2"},
+		{`strings.replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`strings.replace("abc", "", "-")`, "-a-b-c-"},
+		{`strings.replace("a", 1, "b")`, "argument 2 to `strings.replace` must be STRING, got INTEGER"},
+		{`strings.join(["a", 1], "-")`, "element 1 of argument 1 to `strings.join` must be STRING, got INTEGER"},
+		{`strings.join([], "-")`, ""},
+		{`strings.contains("hello", "ell")`, "true"},
+		{`strings.starts_with("hello", "he")`, "true"},
+		{`strings.ends_with("hello", "he")`, "false"},
+		{`strings.index_of("héllo", "l")`, "2"},
+		{`strings.index_of("hello", "z")`, "-1"},
+		{`strings.upper("héllo")`, "HÉLLO"},
+		{`strings.lower("HeLLo")`, "hello"},
+		{`strings.repeat("ab", 3)`, "ababab"},
+		{`strings.repeat("ab", -1)`, "argument to `strings.repeat` must not be negative"},
+		{`strings.repeat("ab", 4611686018427387904)`, "string of 4611686018427387904 times 2 bytes is too large"},
+		{`strings.repeat("", 4611686018427387904)`, ""},
+		{`strings.repeat(1, 2)`, "argument 1 to `strings.repeat` must be STRING, got INTEGER"},
+		{`strings.len("héllo")`, "5"},
+		{`strings.slice("héllo", 1, 3)`, "él"},
+		{`strings.slice("héllo", 3)`, "lo"},
+		{`strings.slice("héllo", 2, 9)`, "slice bounds out of range [2:9] with length 5"},
+		{`strings.format("%s is %d%%", "x", 10)`, "x is 10%"},
+		{`strings.format("%s", [1, "a"])`, "[1, a]"},
+		{`strings.format("%d", "x")`, "%d expects INTEGER, got STRING"},
+		{`strings.format("%s %s", 1)`, "missing argument for %s"},
+		{`strings.format("%s", 1, 2)`, "too many arguments for format: got 2, used 1"},
+		{`strings.format("%x", 1)`, "unknown verb %x"},
+		{`strings.nope`, "module strings has no member nope"},
+		{`split`, "identifier not found: split"},
+	}
+
+	for _, tt := range tests {
+		env := NewSandbox().NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestFloatExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1.5", "1.5"},
+		{"-2.5", "-2.5"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"7 / 2.0", "3.5"},
+		{"0.1 * 3 - 0.3 < 0.001", "true"},
+		{"2.0 == 2", "true"},
+		{"2.5 > 3", "false"},
+		{"1.0 / 0", "division by zero"},
+		{`1.5 + "a"`, "type mismatch: FLOAT + STRING"},
+		{"1.5 < true", "type mismatch: FLOAT < BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"math.pi", "3.141592653589793"},
+		{"math.max_int", "9223372036854775807"},
+		{"math.abs(-3)", "3"},
+		{"math.abs(-2.5)", "2.5"},
+		{"math.abs(math.min_int)", "integer overflow in `math.abs`"},
+		{"math.min(3, 1, 2)", "1"},
+		{"math.max(3, 4.5, 2)", "4.5"},
+		{"math.max()", "wrong number of arguments. got=0, want at least 1"},
+		{`math.min(1, "a")`, "argument 2 to `math.min` must be INTEGER or FLOAT, got STRING"},
+		{"math.pow(2, 10)", "1024"},
+		{"math.pow(2, -1)", "0.5"},
+		{"math.pow(2.0, 0.5) == math.sqrt(2)", "true"},
+		{"math.pow(2, 63)", "integer overflow in `math.pow`"},
+		{"math.pow(-8, 0.5)", "math domain error: pow(-8, 0.5)"},
+		{"math.pow(0, -1)", "math domain error: pow(0, -1)"},
+		{"math.sqrt(16)", "4.0"},
+		{"math.sqrt(-1)", "math domain error: sqrt(-1)"},
+		{"math.floor(2.7)", "2"},
+		{"math.floor(-2.5)", "-3"},
+		{"math.ceil(2.1)", "3"},
+		{"math.round(2.5)", "3"},
+		{"math.round(7)", "7"},
+		{"math.round(math.inf)", "cannot convert +Inf to INTEGER"},
+		{"math.gcd(12, -18)", "6"},
+		{"math.gcd(0, 0)", "0"},
+		{"math.gcd(1.5, 2)", "argument 1: cannot convert FLOAT to int64"},
+		{"math.random(0)", "argument to `math.random` must be positive"},
+		{"let x = math.random(); x < 1.0", "true"},
+		{"math.seed(7); let a = math.random(1000); math.seed(7); a == math.random(1000)", "true"},
+		{"math.seed(7); let a = rand(1000); math.seed(7); a == math.random(1000)", "true"},
+	}
+
+	for _, tt := range tests {
+		env := NewSandbox(CapRandom).NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestMathRandomIsSeeded(t *testing.T) {
+	draw := func(seed int64) string {
+		sandbox := NewSandbox(CapRandom)
+		sandbox.Seed = seed
+		program := parser.New(lexer.New("[math.random(1000000), rand(1000000)]")).ParseProgram()
+		return Eval(program, sandbox.NewEnvironment()).Inspect()
+	}
+
+	if draw(1) != draw(1) {
+		t.Errorf("same seed gave different numbers: %s and %s", draw(1), draw(1))
+	}
+	if draw(1) == draw(2) {
+		t.Errorf("different seeds gave the same numbers: %s", draw(1))
+	}
+
+	// Reseeding or drawing in one environment leaves the others alone.
+	sandbox := NewSandbox(CapRandom)
+	sandbox.Seed = 1
+	other := sandbox.NewEnvironment()
+	Eval(parser.New(lexer.New("math.seed(2); math.random()")).ParseProgram(), sandbox.NewEnvironment())
+	program := parser.New(lexer.New("[math.random(1000000), rand(1000000)]")).ParseProgram()
+	if got := Eval(program, other).Inspect(); got != draw(1) {
+		t.Errorf("seeding another environment changed the numbers. expected=%s, got=%s", draw(1), got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.parse("{\"a\": [1, 2.5, true, null], \"b\": {\"c\": \"d\"}}")`, "{a: [1, 2.5, true, Null], b: {c: d}}"},
+		{`json.parse("1e3")`, "1000.0"},
+		{`json.parse("-12")`, "-12"},
+		{`json.parse("99999999999999999999")`, "1e+20"},
+		{`json.parse("\"\\u00e9\"")`, "é"},
+		{`json.parse("[1,")`, "invalid JSON: unexpected end of input"},
+		{`json.parse("{1: 2}")`, "invalid JSON at offset 2: invalid character '1' looking for beginning of object key string"},
+		{`json.parse("1 2")`, "invalid JSON at offset 3: unexpected data after value"},
+		{`json.parse(1)`, "argument 1 to `json.parse` must be STRING, got INTEGER"},
+		{`json.stringify({"b": [1, 2.0, json.parse("null")], "a": "<\"x\">"})`, `{"a":"<\"x\">","b":[1,2.0,null]}`},
+		{`json.stringify([1, {"k": true}], 2)`, "[\n  1,\n  {\n    \"k\": true\n  }\n]"},
+		{`json.stringify([], "\t")`, "[]"},
+		{`json.stringify({1: 2})`, "cannot encode hash key of type INTEGER as JSON, keys must be STRING"},
+		{`json.stringify([fn(x) { x }])`, "cannot encode FUNCTION as JSON"},
+		{`json.stringify(math.inf)`, "cannot encode +Inf as JSON"},
+		{`json.stringify(1, -1)`, "indent of `json.stringify` must be between 0 and 10, got -1"},
+		{`let v = {"a": [1, "x"]}; json.stringify(json.parse(json.stringify(v)))`, `{"a":[1,"x"]}`},
+	}
+
+	for _, tt := range tests {
+		env := NewSandbox().NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONCycle(t *testing.T) {
+	arr := &object.Array{}
+	arr.Elements = []object.Object{&object.Integer{Value: 1}, arr}
+
+	res := jsonStringify(arr)
+	if err, ok := res.(*object.Error); !ok || err.Message != "cannot encode cyclic ARRAY as JSON" {
+		t.Errorf("wrong result. got=%s", res.Inspect())
+	}
+}
+
+// memFS is an in-memory FileSystem.
+type memFS struct {
+	fstest.MapFS
+}
+
+func (m memFS) Open(name string) (io.ReadCloser, error) {
+	return m.MapFS.Open(name)
+}
+
+func (m memFS) WriteFile(name string, data []byte) error {
+	m.MapFS[name] = &fstest.MapFile{Data: data}
+	return nil
+}
+
+func TestFS(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.read("dir/a.txt")`, "one\ntwo\n"},
+		{`fs.read("missing")`, "open missing: file does not exist"},
+		{`try { fs.read("missing") } catch (e) { e.kind }`, "IOError"},
+		{`fs.write("b.txt", "hi"); fs.read("b.txt")`, "hi"},
+		{`[fs.exists("dir/a.txt"), fs.exists("dir"), fs.exists("nope")]`, "[true, true, false]"},
+		{`fs.list("dir")`, "[a.txt, b.txt]"},
+		{`fs.list("nope")`, "open nope: file does not exist"},
+		{`fs.lines("dir/a.txt")`, "[one, two]"},
+		{`let f = fs.open("dir/a.txt"); [fs.read_line(f), fs.read_line(f), fs.read_line(f)]`, "[one, two, Null]"},
+		{`let f = fs.open("dir/a.txt"); fs.close(f); fs.read_line(f)`, "read_line dir/a.txt: file is closed"},
+		{`fs.open("dir/a.txt")`, "<file dir/a.txt>"},
+		{`fs.read_line("dir/a.txt")`, "argument 1: cannot convert STRING to object.File"},
+		{`read_file("dir/b.txt")`, "b"},
+	}
+
+	for _, tt := range tests {
+		sandbox := NewSandbox(CapFS)
+		sandbox.FS = memFS{fstest.MapFS{
+			"dir/a.txt": {Data: []byte("one\ntwo\n")},
+			"dir/b.txt": {Data: []byte("b")},
+		}}
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), sandbox.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	evaluated := Eval(parser.New(lexer.New(`fs.read("dir/a.txt")`)).ParseProgram(), NewSandbox().NewEnvironment())
+	if evaluated.Inspect() != `fs.read: missing capability "fs"` {
+		t.Errorf("wrong result without capability. got=%q", evaluated.Inspect())
+	}
+}
+
+// TestAllocatingBuiltins checks that builtins creating large values from
+// small arguments are stopped by the memory limit.
+func TestAllocatingBuiltins(t *testing.T) {
+	tests := []string{
+		`strings.repeat("ab", 4000000)`,
+		`strings.join(map(range(20), fn(x) { "a" }), strings.repeat("b", 100000))`,
+		`strings.replace(strings.repeat("a", 1000), "a", strings.repeat("b", 10000))`,
+		`re.replace("a", strings.repeat("a", 1000), strings.repeat("b", 10000))`,
+		`re.replace("(a)", strings.repeat("a", 1000), strings.repeat("$1", 5000))`,
+		`fs.read("big.txt")`,
+	}
+
+	for _, input := range tests {
+		sandbox := NewSandbox(CapFS)
+		sandbox.FS = memFS{fstest.MapFS{"big.txt": {Data: make([]byte, 4<<20)}}}
+		program := parser.New(lexer.New(input)).ParseProgram()
+		evaluated := EvalContext(context.Background(), program, sandbox.NewEnvironment(), Limits{MaxAlloc: 1 << 20})
+		if err, ok := evaluated.(*object.Error); !ok || !errors.Is(err.Err, ErrMaxAlloc) {
+			t.Errorf("expected the memory limit to be hit for %q. got=%.40q", input, evaluated.Inspect())
+		}
+	}
+}
+
+func TestDirFS(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(filepath.Dir(root), "outside.txt"), []byte("secret"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`fs.write("a.txt", "x"); fs.read("./a.txt")`, "x"},
+		{`fs.read("sub/../a.txt")`, "x"},
+		{`fs.read("../outside.txt")`, "open ../outside.txt: path escapes the root directory"},
+		{`fs.write("/tmp/x", "")`, "write /tmp/x: path escapes the root directory"},
+		{`fs.exists("..")`, "stat ..: path escapes the root directory"},
+		{`fs.list(".")`, "[a.txt]"},
+	}
+
+	sandbox := NewSandbox(CapFS)
+	sandbox.FS = DirFS(root)
+	for _, tt := range tests {
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), sandbox.NewEnvironment())
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"time.now()", "2024-02-28T12:30:00Z"},
+		{"now()", "1709123400"},
+		{"time.now() + 12 * time.hour", "2024-02-29T00:30:00Z"},
+		{"time.date(2024, 3, 1) - time.now()", "35h30m0s"},
+		{"time.add_date(time.date(2024, 1, 31), 0, 1, 0)", "2024-03-02T00:00:00Z"},
+		{"time.date(2024, 1, 2, 3, 4, 5)", "2024-01-02T03:04:05Z"},
+		{"time.date(2024, 1, 2, 3, 4, 5, 6)", "wrong number of arguments. got=7, want=3 to 6"},
+		{"time.unix(0)", "1970-01-01T00:00:00Z"},
+		{`time.format(time.now(), time.date_time)`, "2024-02-28 12:30:00"},
+		{`time.format(time.now(), "Jan 2, 2006")`, "Feb 28, 2024"},
+		{`time.parse(time.date_only, "2024-12-25").weekday`, "Wednesday"},
+		{`time.parse(time.date_only, "25/12/2024")`, `parsing time "25/12/2024" as "2006-01-02": cannot parse "25/12/2024" as "2006"`},
+		{`time.duration("1h30m").minutes`, "90.0"},
+		{`time.duration("soon")`, `time: invalid duration "soon"`},
+		{"(1500 * time.millisecond).milliseconds", "1500"},
+		{"time.hour / 4", "15m0s"},
+		{"time.hour / 0", "division by zero"},
+		{"time.hour * 10000000", "duration overflow in `*`"},
+		{"try { 10000000 * time.hour } catch (e) { e.kind }", "ArgumentError"},
+		{"2562047 * time.hour", "2562047h0m0s"},
+		{"time.truncate(time.now(), time.hour)", "2024-02-28T12:00:00Z"},
+		{"let t = time.now(); [t.year, t.month, t.day, t.hour, t.minute, t.second]", "[2024, 2, 28, 12, 30, 0]"},
+		{"time.now() < time.date(2025, 1, 1)", "true"},
+		{"time.now() == time.unix(time.now().unix)", "true"},
+		{"time.minute > time.second", "true"},
+		{"time.now() + time.now()", "unknown operator: TIME + TIME"},
+		{"time.now() * 2", "unknown operator: TIME * INTEGER"},
+		{"time.now().zone", "unknown field zone of TIME"},
+	}
+
+	fixed := time.Date(2024, 2, 28, 12, 30, 0, 0, time.UTC)
+	for _, tt := range tests {
+		in := New(context.Background(), Limits{})
+		in.SetClock(func() time.Time { return fixed })
+		env := NewSandbox(CapTime).NewEnvironment()
+
+		evaluated := in.Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	evaluated := Eval(parser.New(lexer.New("time.now()")).ParseProgram(), NewSandbox().NewEnvironment())
+	if evaluated.Inspect() != `time.now: missing capability "time"` {
+		t.Errorf("wrong result without capability. got=%q", evaluated.Inspect())
+	}
+}
+
+func TestRegexp(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`re.compile("a+b")`, "<regexp a+b>"},
+		{`re.compile("(a")`, `invalid pattern "(a": missing closing )`},
+		{`re.match("^[a-z]+$", "hello")`, "true"},
+		{`let r = re.compile("\\d+"); [re.match(r, "a1"), re.match(r, "ab")]`, "[true, false]"},
+		{`re.find("\\d+", "ab 12 34")`, "12"},
+		{`re.find("\\d+", "ab")`, "Null"},
+		{`re.find_all("\\d+", "1 22 333")`, "[1, 22, 333]"},
+		{`re.find_all("\\d+", "none")`, "[]"},
+		{`re.groups("(\\w+)@(\\w+)(\\.org)?", "mail bob@example now")`, "[bob@example, bob, example, Null]"},
+		{`re.groups("x", "y")`, "Null"},
+		{`re.named("(?P<key>\\w+)=(?P<value>\\w*)", "a=1")`, "{key: a, value: 1}"},
+		{`re.replace("(\\w+)@(\\w+)", "bob@example", "$2 at ${1}")`, "example at bob"},
+		{`re.split("\\s*,\\s*", "a , b,c")`, "[a, b, c]"},
+		{`re.match(1, "a")`, "argument 1 to `re.match` must be STRING or REGEXP, got INTEGER"},
+		{`re.match("a", 1)`, "argument 2 to `re.match` must be STRING, got INTEGER"},
+		{`re.match("a")`, "wrong number of arguments. got=1, want=2"},
+		{`try { re.compile("*") } catch (e) { e.kind }`, "ArgumentError"},
+	}
+
+	for _, tt := range tests {
+		env := NewSandbox().NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6]"},
+		{"map([1, 2], fn(x) { if (x > 1) { return 0; } x })", "[1, 0]"},
+		{`map(["a"], strings.upper)`, "[A]"},
+		{"map([], fn(x) { x })", "[]"},
+		{"map([1], fn(x, y) { x })", "wrong number of arguments to fn(x, y). got=1, want=2"},
+		{"map(1, fn(x) { x })", "argument 1 to `map` must be ARRAY, got INTEGER"},
+		{"map([1], 1)", "argument 2 to `map` must be FUNCTION, got INTEGER"},
+		{`map([1, 2], fn(x) { throw error("boom " + strings.format("%d", x)) })`, "boom 1"},
+		{`try { each([1], fn(x) { throw error("x", "Custom") }) } catch (e) { e.kind }`, "Custom"},
+		{"filter(range(10), fn(x) { x / 3 * 3 == x })", "[0, 3, 6, 9]"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x })", "6"},
+		{"reduce([1, 2, 3], fn(acc, x) { acc + x }, 10)", "16"},
+		{"reduce([], fn(acc, x) { acc + x })", "reduce of empty array with no initial value"},
+		{"reduce([], fn(acc, x) { acc + x }, 0)", "0"},
+		{"each([1, 2], fn(x) { x })", "Null"},
+		{"any([1, 2, 3], fn(x) { x > 2 })", "true"},
+		{"any([], fn(x) { true })", "false"},
+		{"all([1, 2, 3], fn(x) { x > 0 })", "true"},
+		{"all([1, 2, 3], fn(x) { if (x == 2) { false } else { x > 0 } })", "false"},
+		{"all([1, 2], fn(x) { if (x == 2) { throw error(\"stop\") } true })", "stop"},
+		{`sort_by(["ccc", "a", "bb", "d"], strings.len)`, "[a, d, bb, ccc]"},
+		{"sort_by([3, 1.5, 2], fn(x) { x })", "[1.5, 2, 3]"},
+		{`sort_by([{"n": "b"}, {"n": "a"}], fn(h) { h.n })`, "[{n: a}, {n: b}]"},
+		{`sort_by([1, "a"], fn(x) { x })`, "cannot compare sort_by keys INTEGER and STRING"},
+		{"sort_by([true], fn(x) { x })", "sort_by keys must be numbers or strings, got BOOLEAN"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a], [2, b]]"},
+		{"zip()", "wrong number of arguments. got=0, want at least 1"},
+		{"range(4)", "[0, 1, 2, 3]"},
+		{"range(2, 5)", "[2, 3, 4]"},
+		{"range(10, 0, -3)", "[10, 7, 4, 1]"},
+		{"range(0, 10, -1)", "[]"},
+		{"range(0, 1, 0)", "range step must not be zero"},
+		{"range(math.min_int, math.max_int)", "range of 18446744073709551615 integers is too large"},
+		{"range(1048576)[1048575]", "1048575"},
+		{"range(1048577)", "range of 1048577 integers is too large"},
+		{"range(0, 4194304, 4)[1048575]", "4194300"},
+	}
+
+	for _, tt := range tests {
+		env := NewSandbox().NewEnvironment()
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestCollectionsLimits(t *testing.T) {
+	tests := []struct {
+		input    string
+		limits   Limits
+		expected error
+	}{
+		{"each(range(1000), fn(x) { x })", Limits{MaxSteps: 500}, ErrMaxSteps},
+		{"each(range(1000), math.abs)", Limits{MaxSteps: 500}, ErrMaxSteps},
+		{"range(1000000)", Limits{MaxAlloc: 1 << 20}, ErrMaxAlloc},
+		{"let f = fn(x) { map([x], f) }; f(1)", Limits{MaxDepth: 50}, ErrMaxDepth},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(context.Background(), program, NewSandbox().NewEnvironment(), tt.limits)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q. got=%s", tt.input, evaluated.Inspect())
+			continue
+		}
+		if !errors.Is(errObj.Err, tt.expected) {
+			t.Errorf("wrong error for %q. expected=%v, got=%v (%s)", tt.input, tt.expected, errObj.Err, errObj.Message)
+		}
+	}
+
+	// Iterating natively needs no stack, unlike the recursive equivalent.
+	program := parser.New(lexer.New("reduce(range(100000), fn(acc, x) { acc + x }, 0)")).ParseProgram()
+	evaluated := EvalContext(context.Background(), program, NewSandbox().NewEnvironment(), Limits{MaxDepth: 10})
+	if evaluated.Inspect() != "4999950000" {
+		t.Errorf("wrong result. got=%s", evaluated.Inspect())
+	}
+}
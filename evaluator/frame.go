@@ -0,0 +1,114 @@
+package evaluator
+
+import (
+	"inter/ast"
+	"inter/object"
+)
+
+// Frame is an entry of the call stack: the program itself, an imported
+// module being evaluated or a call to a script function. A call in tail
+// position replaces the function and environment of the frame making it.
+type Frame struct {
+	Function  *object.Function    // nil for the program and modules
+	Module    *object.Module      // the module being evaluated, if any
+	Call      *ast.CallExpression // the call site, nil for the program
+	Env       *object.Environment
+	Statement ast.Statement // the statement being evaluated, if any
+	TailCalls int           // the number of tail calls that replaced the function
+}
+
+// Name returns the name the function was bound to with let, "<anonymous>"
+// for unnamed functions, "<module name>" for modules and "<main>" for the
+// program.
+func (f *Frame) Name() string {
+	switch {
+	case f.Module != nil:
+		return "<module " + f.Module.Name + ">"
+	case f.Function == nil:
+		return "<main>"
+	case f.Function.Name == "":
+		return "<anonymous>"
+	default:
+		return f.Function.Name
+	}
+}
+
+// Hook is notified by the interpreter before each statement of a program or
+// block is evaluated. The evaluation is suspended until the hook returns.
+type Hook interface {
+	BeforeStatement(in *Interpreter, st ast.Statement, env *object.Environment)
+}
+
+// BranchHook is implemented by hooks that also want to know which arm of
+// each if expression is taken. Branch is called after the condition is
+// evaluated, before the arm.
+type BranchHook interface {
+	Hook
+	Branch(in *Interpreter, exp *ast.IfExpression, taken bool)
+}
+
+// SetHook installs h, replacing the previous hook. A nil h removes it.
+func (in *Interpreter) SetHook(h Hook) {
+	in.hook = h
+}
+
+// Frames returns a copy of the call stack, innermost frame first.
+func (in *Interpreter) Frames() []Frame {
+	frames := make([]Frame, len(in.frames))
+	for i, f := range in.frames {
+		frames[len(frames)-1-i] = *f
+	}
+	return frames
+}
+
+func (in *Interpreter) pushFrame(f *Frame) {
+	in.frames = append(in.frames, f)
+}
+
+func (in *Interpreter) popFrame() {
+	in.frames = in.frames[:len(in.frames)-1]
+}
+
+// stackTrace returns the stack of an error occurring in node.
+func (in *Interpreter) stackTrace(node ast.Node) []object.StackFrame {
+	tok := ast.StartToken(node)
+	if len(in.frames) == 0 {
+		return []object.StackFrame{{Function: "<main>", Line: tok.Line, Column: tok.Column}}
+	}
+
+	stack := make([]object.StackFrame, 0, len(in.frames))
+	for i := len(in.frames) - 1; i >= 0; i-- {
+		f := in.frames[i]
+		frame := object.StackFrame{Function: f.Name(), Line: tok.Line, Column: tok.Column, Elided: f.TailCalls}
+		if in.loader != nil {
+			frame.File = in.loader.File(f.Env)
+		}
+		stack = append(stack, frame)
+		switch {
+		case f.Call != nil:
+			tok = ast.StartToken(f.Call)
+		case i > 0 && in.frames[i-1].Statement != nil:
+			// A module is evaluated by the import statement of the frame
+			// below it.
+			tok = ast.StartToken(in.frames[i-1].Statement)
+		}
+	}
+	return stack
+}
+
+// beforeStatement records st as the current statement of the innermost
+// frame and notifies the hook.
+func (in *Interpreter) beforeStatement(st ast.Statement, env *object.Environment) {
+	if len(in.frames) > 0 {
+		in.frames[len(in.frames)-1].Statement = st
+	}
+
+	if in.hook != nil {
+		in.hook.BeforeStatement(in, st, env)
+	}
+}
+
+// Depth returns the number of frames on the call stack.
+func (in *Interpreter) Depth() int {
+	return len(in.frames)
+}
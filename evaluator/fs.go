@@ -0,0 +1,246 @@
+package evaluator
+
+import (
+	"bufio"
+	"errors"
+	"inter/object"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileSystem is the file system scripts access through the fs namespace.
+// Hosts provide one to restrict scripts to a directory with DirFS, or to
+// keep them away from the disk in tests. Names use forward slashes.
+type FileSystem interface {
+	Open(name string) (io.ReadCloser, error)
+	WriteFile(name string, data []byte) error
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+}
+
+// HostFS is the file system of the host, without restrictions. Relative
+// names are relative to the working directory.
+type HostFS struct{}
+
+func (HostFS) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.FromSlash(name))
+}
+
+func (HostFS) WriteFile(name string, data []byte) error {
+	return os.WriteFile(filepath.FromSlash(name), data, 0666)
+}
+
+func (HostFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(filepath.FromSlash(name))
+}
+
+func (HostFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(filepath.FromSlash(name))
+}
+
+// DirFS returns the file system of the files under root. Names are
+// relative to root, and names escaping it, such as absolute names or
+// names starting with "..", are rejected. Like os.DirFS, it does not
+// guard against symbolic links pointing outside of root.
+func DirFS(root string) FileSystem {
+	return dirFS{root: root}
+}
+
+type dirFS struct {
+	root string
+}
+
+// errEscapesRoot is returned for names outside of the root of a DirFS.
+var errEscapesRoot = errors.New("path escapes the root directory")
+
+func (d dirFS) resolve(op, name string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") || filepath.VolumeName(name) != "" {
+		return "", &fs.PathError{Op: op, Path: name, Err: errEscapesRoot}
+	}
+	return filepath.Join(d.root, filepath.FromSlash(clean)), nil
+}
+
+func (d dirFS) Open(name string) (io.ReadCloser, error) {
+	file, err := d.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(file)
+}
+
+func (d dirFS) WriteFile(name string, data []byte) error {
+	file, err := d.resolve("write", name)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0666)
+}
+
+func (d dirFS) Stat(name string) (fs.FileInfo, error) {
+	file, err := d.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(file)
+}
+
+func (d dirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	file, err := d.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadDir(file)
+}
+
+// readFile reads the whole file name of fsys.
+func readFile(fsys FileSystem, name string) ([]byte, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// readFile reads the whole file name of fsys like the readFile function,
+// accounting for the size of the file before reading it, so that reading
+// a large file fails on the memory limit before using the memory.
+func (in *Interpreter) readFile(fsys FileSystem, name string) ([]byte, *object.Error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, ioError(err)
+	}
+	defer f.Close()
+
+	size := int64(0)
+	if info, err := fsys.Stat(name); err == nil {
+		size = info.Size()
+	}
+	if err := in.alloc(size); err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, ioError(err)
+	}
+	// The file may have grown, or not know its size, like pipes.
+	if extra := int64(len(content)) - size; extra > 0 {
+		if err := in.alloc(extra); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+// ioError returns the error of a failed file system operation.
+func ioError(err error) *object.Error {
+	return &object.Error{Message: err.Error(), Kind: object.KindIO, Err: err}
+}
+
+// fsNamespace holds the file functions, operating on fsys. Files can be
+// read whole, as an array of lines, or line by line with open, read_line
+// and close.
+func (s *Sandbox) fsNamespace(fsys FileSystem) *object.Module {
+	members := map[string]object.Object{
+		"read": &Native{Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			strs, err := stringArguments("fs.read", args, 1)
+			if err != nil {
+				return err
+			}
+			content, err := in.readFile(fsys, strs[0])
+			if err != nil {
+				return err
+			}
+			return &object.String{Value: string(content)}
+		}},
+		"write": mustBuiltin(func(name, content string) object.Object {
+			if err := fsys.WriteFile(name, []byte(content)); err != nil {
+				return ioError(err)
+			}
+			return NULL
+		}),
+		"exists": mustBuiltin(func(name string) object.Object {
+			_, err := fsys.Stat(name)
+			switch {
+			case err == nil:
+				return TRUE
+			case errors.Is(err, fs.ErrNotExist):
+				return FALSE
+			default:
+				return ioError(err)
+			}
+		}),
+		"list": mustBuiltin(func(name string) object.Object {
+			entries, err := fsys.ReadDir(name)
+			if err != nil {
+				return ioError(err)
+			}
+			names := []string{}
+			for _, entry := range entries {
+				names = append(names, entry.Name())
+			}
+			sort.Strings(names)
+			res, _ := object.ToObject(names)
+			return res
+		}),
+		"lines": mustBuiltin(func(name string) object.Object {
+			f, err := fsys.Open(name)
+			if err != nil {
+				return ioError(err)
+			}
+			defer f.Close()
+
+			lines := []object.Object{}
+			scanner := bufio.NewScanner(f)
+			for scanner.Scan() {
+				lines = append(lines, &object.String{Value: scanner.Text()})
+			}
+			if err := scanner.Err(); err != nil {
+				return ioError(err)
+			}
+			return &object.Array{Elements: lines}
+		}),
+		"open": mustBuiltin(func(name string) object.Object {
+			f, err := fsys.Open(name)
+			if err != nil {
+				return ioError(err)
+			}
+			return &object.File{Name: name, Scanner: bufio.NewScanner(f), Closer: f}
+		}),
+		"read_line": mustBuiltin(func(f *object.File) object.Object {
+			if f.Closer == nil {
+				return newError(object.KindIO, "read_line %s: file is closed", f.Name)
+			}
+			if f.Scanner.Scan() {
+				return &object.String{Value: f.Scanner.Text()}
+			}
+			if err := f.Scanner.Err(); err != nil {
+				return ioError(err)
+			}
+			return NULL
+		}),
+		"close": mustBuiltin(func(f *object.File) object.Object {
+			if f.Closer == nil {
+				return NULL
+			}
+			err := f.Closer.Close()
+			f.Closer = nil
+			if err != nil {
+				return ioError(err)
+			}
+			return NULL
+		}),
+	}
+
+	for name, fn := range members {
+		members[name] = s.guard("fs."+name, CapFS, fn)
+	}
+	return namespace("fs", members)
+}
@@ -0,0 +1,111 @@
+package evaluator
+
+import (
+	"context"
+	"errors"
+	"inter/ast"
+	"inter/object"
+	"time"
+)
+
+var (
+	ErrMaxDepth = errors.New("maximum call depth exceeded")
+	ErrMaxSteps = errors.New("maximum evaluation steps exceeded")
+	ErrMaxAlloc = errors.New("memory limit exceeded")
+)
+
+// Limits bounds the work a single evaluation may do, so that untrusted
+// scripts cannot hang or crash the host. Zero values mean no limit.
+type Limits struct {
+	MaxDepth int   // nesting of function calls
+	MaxSteps int64 // number of evaluated nodes
+	MaxAlloc int64 // estimated bytes allocated, see Interpreter.Allocated
+}
+
+// DefaultMaxDepth is the MaxDepth of the inter commands. It bounds recursion
+// so that runaway scripts fail with a traceback instead of overflowing the
+// Go stack.
+const DefaultMaxDepth = 10000
+
+// Interpreter holds the state of one evaluation run. It is not safe for
+// concurrent use, create one Interpreter per goroutine.
+type Interpreter struct {
+	ctx    context.Context
+	done   <-chan struct{}
+	limits Limits
+
+	depth     int
+	steps     int64
+	allocated int64
+
+	hook   Hook
+	frames []*Frame
+	loader *Loader
+	clock  func() time.Time
+
+	sampler Sampler
+	ticks   int32 // sampling periods elapsed, updated atomically
+}
+
+func New(ctx context.Context, limits Limits) *Interpreter {
+	return &Interpreter{ctx: ctx, done: ctx.Done(), limits: limits}
+}
+
+// Eval evaluates node without any limits.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(context.Background(), Limits{}).Eval(node, env)
+}
+
+// EvalContext evaluates node until it finishes, ctx is done or one of the
+// limits is hit. Hitting a limit returns an *object.Error whose Err is
+// ErrMaxDepth, ErrMaxSteps, ErrMaxAlloc or the error of ctx.
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, limits Limits) object.Object {
+	return New(ctx, limits).Eval(node, env)
+}
+
+// Steps reports the number of nodes evaluated so far.
+func (in *Interpreter) Steps() int64 {
+	return in.steps
+}
+
+func (in *Interpreter) step() *object.Error {
+	in.steps++
+	if in.limits.MaxSteps > 0 && in.steps > in.limits.MaxSteps {
+		return limitError(ErrMaxSteps)
+	}
+
+	select {
+	case <-in.done:
+		return &object.Error{Message: "evaluation cancelled: " + in.ctx.Err().Error(), Kind: object.KindCancelled, Err: in.ctx.Err()}
+	default:
+		return nil
+	}
+}
+
+func (in *Interpreter) enter() *object.Error {
+	if in.limits.MaxDepth > 0 && in.depth >= in.limits.MaxDepth {
+		return limitError(ErrMaxDepth)
+	}
+
+	in.depth++
+	return nil
+}
+
+func (in *Interpreter) leave() {
+	in.depth--
+}
+
+// catchable reports whether a try expression may catch err: limits and
+// cancellation always abort the evaluation.
+func catchable(err *object.Error) bool {
+	for _, target := range []error{ErrMaxDepth, ErrMaxSteps, ErrMaxAlloc, context.Canceled, context.DeadlineExceeded} {
+		if errors.Is(err.Err, target) {
+			return false
+		}
+	}
+	return true
+}
+
+func limitError(err error) *object.Error {
+	return &object.Error{Message: err.Error(), Kind: object.KindLimit, Err: err}
+}
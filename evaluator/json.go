@@ -0,0 +1,224 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"inter/object"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonNamespace holds json.parse and json.stringify, converting between
+// JSON text and script values: objects are hashes, numbers are integers
+// unless they have a fraction or an exponent, and null is NULL.
+func jsonNamespace() *object.Module {
+	return namespace("json", map[string]object.Object{
+		"parse":     &object.Builtin{Fn: jsonParse},
+		"stringify": &object.Builtin{Fn: jsonStringify},
+	})
+}
+
+func jsonParse(args ...object.Object) object.Object {
+	if err := arity(args, 1); err != nil {
+		return err
+	}
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.KindType, "argument 1 to `json.parse` must be STRING, got %s", args[0].Type())
+	}
+
+	dec := json.NewDecoder(strings.NewReader(str.Value))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return jsonSyntaxError(err, dec)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return newError(object.KindArgument, "invalid JSON at offset %d: unexpected data after value", dec.InputOffset())
+	}
+
+	return fromJSON(v)
+}
+
+func jsonSyntaxError(err error, dec *json.Decoder) *object.Error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return newError(object.KindArgument, "invalid JSON: unexpected end of input")
+	}
+	if serr, ok := err.(*json.SyntaxError); ok {
+		return newError(object.KindArgument, "invalid JSON at offset %d: %s", serr.Offset, serr)
+	}
+	return newError(object.KindArgument, "invalid JSON at offset %d: %s", dec.InputOffset(), err)
+}
+
+// fromJSON converts a value decoded by encoding/json with UseNumber.
+func fromJSON(v interface{}) object.Object {
+	switch v := v.(type) {
+	case nil:
+		return NULL
+	case bool:
+		return getBool(v)
+	case string:
+		return &object.String{Value: v}
+	case json.Number:
+		if !strings.ContainsAny(string(v), ".eE") {
+			if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+				return &object.Integer{Value: i}
+			}
+		}
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return newError(object.KindArgument, "invalid JSON: number %s out of range", v)
+		}
+		return &object.Float{Value: f}
+	case []interface{}:
+		elements := make([]object.Object, len(v))
+		for i, el := range v {
+			elements[i] = fromJSON(el)
+			if isError(elements[i]) {
+				return elements[i]
+			}
+		}
+		return &object.Array{Elements: elements}
+	case map[string]interface{}:
+		pairs := make(map[object.HashKey]object.HashPair, len(v))
+		for k, el := range v {
+			key := &object.String{Value: k}
+			val := fromJSON(el)
+			if isError(val) {
+				return val
+			}
+			pairs[key.HashKey()] = object.HashPair{Key: key, Value: val}
+		}
+		return &object.Hash{Pairs: pairs}
+	default:
+		return newError(object.KindError, "unexpected JSON value %T", v)
+	}
+}
+
+// jsonStringify implements json.stringify(value, indent). indent is a
+// number of spaces or a string used to indent nested values; without it
+// the text is compact. Hash keys are sorted, so the output is stable.
+func jsonStringify(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 || arg.Value > 10 {
+				return newError(object.KindArgument, "indent of `json.stringify` must be between 0 and 10, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newError(object.KindType, "argument 2 to `json.stringify` must be INTEGER or STRING, got %s", arg.Type())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeJSON(&out, args[0], nil); err != nil {
+		return err
+	}
+	if indent == "" {
+		return &object.String{Value: out.String()}
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, out.Bytes(), "", indent); err != nil {
+		return newError(object.KindError, "%s", err)
+	}
+	return &object.String{Value: indented.String()}
+}
+
+// writeJSON writes the compact encoding of obj to out. path holds the
+// arrays and hashes being written, to detect cycles.
+func writeJSON(out *bytes.Buffer, obj object.Object, path []object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		out.WriteString("null")
+	case *object.Boolean:
+		out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError(object.KindArgument, "cannot encode %s as JSON", obj.Inspect())
+		}
+		out.WriteString(obj.Inspect())
+	case *object.String:
+		writeJSONString(out, obj.Value)
+
+	case *object.Array:
+		if err := checkCycle(obj, path); err != nil {
+			return err
+		}
+		out.WriteByte('[')
+		for i, el := range obj.Elements {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			if err := writeJSON(out, el, append(path, obj)); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+
+	case *object.Hash:
+		if err := checkCycle(obj, path); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(obj.Pairs))
+		values := make(map[string]object.Object, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError(object.KindType, "cannot encode hash key of type %s as JSON, keys must be STRING", pair.Key.Type())
+			}
+			keys = append(keys, key.Value)
+			values[key.Value] = pair.Value
+		}
+		sort.Strings(keys)
+
+		out.WriteByte('{')
+		for i, key := range keys {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+			writeJSONString(out, key)
+			out.WriteByte(':')
+			if err := writeJSON(out, values[key], append(path, obj)); err != nil {
+				return err
+			}
+		}
+		out.WriteByte('}')
+
+	default:
+		return newError(object.KindType, "cannot encode %s as JSON", obj.Type())
+	}
+	return nil
+}
+
+func checkCycle(obj object.Object, path []object.Object) *object.Error {
+	for _, p := range path {
+		if p == obj {
+			return newError(object.KindArgument, "cannot encode cyclic %s as JSON", obj.Type())
+		}
+	}
+	return nil
+}
+
+// writeJSONString writes s as a JSON string, without escaping HTML
+// characters as encoding/json does by default.
+func writeJSONString(out *bytes.Buffer, s string) {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode terminates the value with a newline.
+	out.Truncate(out.Len() - 1)
+}
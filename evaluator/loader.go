@@ -0,0 +1,238 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"inter/ast"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Loader loads the modules imported by scripts. Each file is evaluated
+// once, in its own environment, and the module is shared by all the
+// scripts importing it.
+//
+// An import path starting with ./ or ../ is relative to the directory of
+// the importing file. Other relative paths are looked up in that
+// directory first, then in each directory of the search path. The .mk
+// extension may be omitted. Modules are read from the file system of the
+// loader, so a loader created by a sandbox restricted to a directory
+// refuses imports outside of it. A Loader is not safe for concurrent use.
+type Loader struct {
+	// SearchPath lists the directories searched for imports that are not
+	// explicitly relative.
+	SearchPath []string
+
+	// NewEnvironment returns the environment a module is evaluated in,
+	// with the builtins it may use.
+	NewEnvironment func() *object.Environment
+
+	// FS is the file system modules are read from, HostFS if nil. Modules
+	// of the host file system are identified by their absolute path, and
+	// those of other file systems by their cleaned name.
+	FS FileSystem
+
+	// Loaded, if not nil, is called with each module before it is
+	// evaluated, for example to record its coverage.
+	Loaded func(path, src string, program *ast.Program)
+
+	sandbox *Sandbox // whose fs capability imports need, if not nil
+
+	modules map[string]*object.Module
+	files   map[*object.Environment]string // the file of each top-level environment
+	loading []string                       // the files being loaded, for cycle detection
+}
+
+// SearchPath returns the directories listed in $INTER_PATH, the search
+// path of the inter command.
+func SearchPath() []string {
+	return filepath.SplitList(os.Getenv("INTER_PATH"))
+}
+
+// NewLoader returns a loader evaluating modules in environments returned
+// by newEnv, and looking up imports in searchPath.
+func NewLoader(newEnv func() *object.Environment, searchPath ...string) *Loader {
+	return &Loader{
+		SearchPath:     searchPath,
+		NewEnvironment: newEnv,
+		modules:        make(map[string]*object.Module),
+		files:          make(map[*object.Environment]string),
+	}
+}
+
+// NewLoader returns a loader evaluating modules in environments of the
+// sandbox and reading them from its file system. Imports fail unless the
+// sandbox allows CapFS.
+func (s *Sandbox) NewLoader(searchPath ...string) *Loader {
+	l := NewLoader(s.NewEnvironment, searchPath...)
+	l.FS = s.FS
+	l.sandbox = s
+	return l
+}
+
+// SetLoader makes l load the modules imported by the programs the
+// interpreter evaluates. Without a loader, imports fail.
+func (in *Interpreter) SetLoader(l *Loader) {
+	in.loader = l
+}
+
+// SetFile records that env is the top-level environment of the program in
+// file, so that the imports of the program are resolved relative to it
+// and its positions in stack traces are prefixed with it.
+func (l *Loader) SetFile(env *object.Environment, file string) {
+	l.files[env] = file
+}
+
+// File returns the file of the program or module env belongs to, as
+// recorded by SetFile, empty if unknown.
+func (l *Loader) File(env *object.Environment) string {
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	return l.files[env]
+}
+
+func (l *Loader) fs() FileSystem {
+	if l.FS == nil {
+		return HostFS{}
+	}
+	return l.FS
+}
+
+// resolve returns the file imported by path from a file in dir.
+func (l *Loader) resolve(dir, path string) (string, error) {
+	if !strings.HasSuffix(path, ".mk") {
+		path += ".mk"
+	}
+	path = filepath.FromSlash(path)
+
+	candidates := []string{path}
+	if !filepath.IsAbs(path) {
+		candidates = []string{filepath.Join(dir, path)}
+		explicit := strings.HasPrefix(path, "."+string(filepath.Separator)) || strings.HasPrefix(path, ".."+string(filepath.Separator))
+		if !explicit {
+			for _, searchDir := range l.SearchPath {
+				candidates = append(candidates, filepath.Join(searchDir, path))
+			}
+		}
+	}
+
+	for _, file := range candidates {
+		_, err := l.fs().Stat(filepath.ToSlash(file))
+		switch {
+		case err == nil && l.FS == nil:
+			return filepath.Abs(file)
+		case err == nil:
+			return file, nil
+		case !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("module not found: %s", strings.Join(candidates, ", "))
+}
+
+func (in *Interpreter) evalImportStatement(st *ast.ImportStatement, env *object.Environment) object.Object {
+	if in.loader == nil {
+		return newError(object.KindImport, "imports are not supported here")
+	}
+
+	mod := in.load(st.Path.Value, env)
+	if isError(mod) {
+		return mod
+	}
+	if err := in.alloc(bindingSize); err != nil {
+		return err
+	}
+
+	env.Set(st.Name(), mod)
+	return mod
+}
+
+// load returns the module imported by path from the program of env,
+// evaluating it on first use.
+func (in *Interpreter) load(path string, env *object.Environment) object.Object {
+	l := in.loader
+	if l.sandbox != nil && !l.sandbox.Allows(CapFS) {
+		return newError(object.KindCapability, "import: missing capability %q", CapFS)
+	}
+
+	dir := "."
+	if importer := l.File(env); importer != "" {
+		dir = filepath.Dir(importer)
+	}
+	file, err := l.resolve(dir, path)
+	if err != nil {
+		return newError(object.KindImport, "%s", err)
+	}
+
+	for i, loading := range l.loading {
+		if loading == file {
+			cycle := append(append([]string{}, l.loading[i:]...), file)
+			return newError(object.KindImport, "import cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	if mod, ok := l.modules[file]; ok {
+		return mod
+	}
+
+	src, err := readFile(l.fs(), filepath.ToSlash(file))
+	if err != nil {
+		return newError(object.KindImport, "%s", err)
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", file, err))
+		}
+		return newError(object.KindImport, "%s", strings.Join(msgs, "\n"))
+	}
+
+	if l.Loaded != nil {
+		l.Loaded(file, string(src), program)
+	}
+
+	mod := &object.Module{Name: ast.ModuleName(path), Path: file, Env: object.NewEnvironment()}
+	if l.NewEnvironment != nil {
+		mod.Env = l.NewEnvironment()
+	}
+	l.SetFile(mod.Env, file)
+
+	l.loading = append(l.loading, file)
+	in.pushFrame(&Frame{Module: mod, Env: mod.Env})
+	res := in.Eval(program, mod.Env)
+	in.popFrame()
+	l.loading = l.loading[:len(l.loading)-1]
+
+	if isError(res) {
+		return res
+	}
+
+	for _, st := range program.Statements {
+		if let, ok := st.(*ast.LetStatement); ok && !strings.HasPrefix(let.Name.Value, "_") {
+			mod.Exports = appendUnique(mod.Exports, let.Name.Value)
+		}
+	}
+	if res := in.track(mod); isError(res) {
+		return res
+	}
+	l.modules[file] = mod
+	return mod
+}
+
+func appendUnique(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
@@ -0,0 +1,278 @@
+package evaluator
+
+import (
+	"errors"
+	"inter/object"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// mathNamespace holds the numeric functions and constants. Functions keep
+// integers as integers where the result is exact, and accept floats
+// anywhere a number is expected. random draws from r, so it is
+// deterministic, and math.seed(n) reseeds r. Both need CapRandom.
+func (s *Sandbox) mathNamespace(r *lockedRand) *object.Module {
+	return namespace("math", map[string]object.Object{
+		"pi":      &object.Float{Value: math.Pi},
+		"e":       &object.Float{Value: math.E},
+		"inf":     &object.Float{Value: math.Inf(1)},
+		"max_int": &object.Integer{Value: math.MaxInt64},
+		"min_int": &object.Integer{Value: math.MinInt64},
+
+		"abs":    &object.Builtin{Fn: mathAbs},
+		"min":    &object.Builtin{Fn: func(args ...object.Object) object.Object { return extremum("min", args, -1) }},
+		"max":    &object.Builtin{Fn: func(args ...object.Object) object.Object { return extremum("max", args, 1) }},
+		"pow":    &object.Builtin{Fn: mathPow},
+		"sqrt":   &object.Builtin{Fn: mathSqrt},
+		"floor":  &object.Builtin{Fn: rounding("floor", math.Floor)},
+		"ceil":   &object.Builtin{Fn: rounding("ceil", math.Ceil)},
+		"round":  &object.Builtin{Fn: rounding("round", math.Round)},
+		"gcd":    mustBuiltin(gcd),
+		"random": s.guard("math.random", CapRandom, &object.Builtin{Fn: r.random}),
+		"seed": s.guard("math.seed", CapRandom, mustBuiltin(func(seed int64) {
+			r.seed(seed)
+		})),
+	})
+}
+
+// number returns the i-th argument of the math function name as a float,
+// or an error if it is not a number.
+func number(name string, args []object.Object, i int) (float64, *object.Error) {
+	f, ok := toFloat(args[i])
+	if !ok {
+		return 0, newError(object.KindType, "argument %d to `math.%s` must be INTEGER or FLOAT, got %s", i+1, name, args[i].Type())
+	}
+	return f, nil
+}
+
+func arity(args []object.Object, want int) *object.Error {
+	if len(args) != want {
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=%d", len(args), want)
+	}
+	return nil
+}
+
+func mathAbs(args ...object.Object) object.Object {
+	if err := arity(args, 1); err != nil {
+		return err
+	}
+
+	if n, ok := args[0].(*object.Integer); ok {
+		if n.Value == math.MinInt64 {
+			return newError(object.KindArgument, "integer overflow in `math.abs`")
+		}
+		if n.Value < 0 {
+			return &object.Integer{Value: -n.Value}
+		}
+		return n
+	}
+
+	f, err := number("abs", args, 0)
+	if err != nil {
+		return err
+	}
+	return &object.Float{Value: math.Abs(f)}
+}
+
+// extremum returns the smallest of args if sign is -1, the largest if it
+// is 1. Integers are compared exactly, mixed numbers as floats.
+func extremum(name string, args []object.Object, sign int) object.Object {
+	if len(args) == 0 {
+		return newError(object.KindArgument, "wrong number of arguments. got=0, want at least 1")
+	}
+
+	best := args[0]
+	for i := range args {
+		f, err := number(name, args, i)
+		if err != nil {
+			return err
+		}
+
+		cmp := 0
+		x, xInt := args[i].(*object.Integer)
+		y, yInt := best.(*object.Integer)
+		if xInt && yInt {
+			switch {
+			case x.Value < y.Value:
+				cmp = -1
+			case x.Value > y.Value:
+				cmp = 1
+			}
+		} else {
+			b, _ := toFloat(best)
+			switch {
+			case f < b:
+				cmp = -1
+			case f > b:
+				cmp = 1
+			}
+		}
+
+		if cmp == sign {
+			best = args[i]
+		}
+	}
+	return best
+}
+
+// mathPow returns an integer when both arguments are integers and the
+// exponent is not negative, a float otherwise.
+func mathPow(args ...object.Object) object.Object {
+	if err := arity(args, 2); err != nil {
+		return err
+	}
+
+	base, baseInt := args[0].(*object.Integer)
+	exp, expInt := args[1].(*object.Integer)
+	if baseInt && expInt && exp.Value >= 0 {
+		res, ok := powInt(base.Value, exp.Value)
+		if !ok {
+			return newError(object.KindArgument, "integer overflow in `math.pow`")
+		}
+		return &object.Integer{Value: res}
+	}
+
+	x, err := number("pow", args, 0)
+	if err != nil {
+		return err
+	}
+	y, err := number("pow", args, 1)
+	if err != nil {
+		return err
+	}
+
+	res := math.Pow(x, y)
+	if math.IsNaN(res) || (x == 0 && y < 0) {
+		return newError(object.KindArgument, "math domain error: pow(%s, %s)", args[0].Inspect(), args[1].Inspect())
+	}
+	return &object.Float{Value: res}
+}
+
+// powInt returns base**exp by repeated squaring, and false if it
+// overflows.
+func powInt(base, exp int64) (int64, bool) {
+	res := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			if !mulInt(&res, base) {
+				return 0, false
+			}
+		}
+		exp >>= 1
+		if exp > 0 && !mulInt(&base, base) {
+			return 0, false
+		}
+	}
+	return res, true
+}
+
+// mulInt multiplies *x by y, and returns false if the product overflows.
+func mulInt(x *int64, y int64) bool {
+	p := *x * y
+	if *x != 0 && (p / *x != y || (*x == -1 && y == math.MinInt64)) {
+		return false
+	}
+	*x = p
+	return true
+}
+
+func mathSqrt(args ...object.Object) object.Object {
+	if err := arity(args, 1); err != nil {
+		return err
+	}
+
+	f, err := number("sqrt", args, 0)
+	if err != nil {
+		return err
+	}
+	if f < 0 {
+		return newError(object.KindArgument, "math domain error: sqrt(%s)", args[0].Inspect())
+	}
+	return &object.Float{Value: math.Sqrt(f)}
+}
+
+// rounding returns the math function name rounding a float to an integer
+// with round. Integers are returned unchanged.
+func rounding(name string, round func(float64) float64) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		if err := arity(args, 1); err != nil {
+			return err
+		}
+		if n, ok := args[0].(*object.Integer); ok {
+			return n
+		}
+
+		f, err := number(name, args, 0)
+		if err != nil {
+			return err
+		}
+		r := round(f)
+		// The float64 nearest to MaxInt64 is 2**63, which overflows.
+		if math.IsNaN(r) || r < math.MinInt64 || r >= math.MaxInt64 {
+			return newError(object.KindArgument, "cannot convert %s to INTEGER", args[0].Inspect())
+		}
+		return &object.Integer{Value: int64(r)}
+	}
+}
+
+// gcd returns the greatest common divisor of a and b, which is never
+// negative.
+func gcd(a, b int64) (int64, error) {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	if a == math.MinInt64 {
+		return 0, errors.New("integer overflow in `math.gcd`")
+	}
+	if a < 0 {
+		a = -a
+	}
+	return a, nil
+}
+
+// lockedRand is the random number generator of an environment, shared by
+// the scripts enclosing it, which may run concurrently.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{r: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Int63n(n)
+}
+
+func (r *lockedRand) seed(seed int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.r.Seed(seed)
+}
+
+// random implements math.random(), a float in [0, 1), and math.random(n),
+// an integer in [0, n).
+func (r *lockedRand) random(args ...object.Object) object.Object {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch len(args) {
+	case 0:
+		return &object.Float{Value: r.r.Float64()}
+	case 1:
+		n, ok := args[0].(*object.Integer)
+		if !ok {
+			return newError(object.KindType, "argument 1 to `math.random` must be INTEGER, got %s", args[0].Type())
+		}
+		if n.Value <= 0 {
+			return newError(object.KindArgument, "argument to `math.random` must be positive")
+		}
+		return &object.Integer{Value: r.r.Int63n(n.Value)}
+	default:
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=0 or 1", len(args))
+	}
+}
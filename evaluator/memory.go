@@ -0,0 +1,67 @@
+package evaluator
+
+import (
+	"inter/object"
+)
+
+// Rough sizes in bytes of the values created during evaluation. They do not
+// need to match the Go runtime exactly, only to grow with what a script
+// allocates.
+const (
+	objectSize   = 16 // interface value pointing to a small struct
+	elementSize  = 16 // one slot of an array
+	pairSize     = 48 // one entry of a hash: key, pair and map overhead
+	functionSize = 64 // closure: parameters, body and captured environment
+	envSize      = 48 // an empty environment
+	bindingSize  = 32 // one name in an environment
+)
+
+// sizeOf estimates the bytes needed by obj itself, not counting the objects
+// it refers to, which are accounted for when they are created.
+func sizeOf(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer, *object.Float, *object.Time, *object.Duration, *object.Regexp:
+		return objectSize
+	case *object.String:
+		return objectSize + int64(len(obj.Value))
+	case *object.Array:
+		return objectSize + elementSize*int64(len(obj.Elements))
+	case *object.Hash:
+		return objectSize + pairSize*int64(len(obj.Pairs))
+	case *object.Function:
+		return functionSize + 8*int64(len(obj.Parameters))
+	case *object.Builtin, *Native:
+		return objectSize
+	case *object.Module:
+		return objectSize + envSize
+	default:
+		// Booleans and null are shared, errors and return values are
+		// transient wrappers.
+		return 0
+	}
+}
+
+// Allocated reports the estimated number of bytes allocated so far. Memory
+// released by the garbage collector is not subtracted.
+func (in *Interpreter) Allocated() int64 {
+	return in.allocated
+}
+
+func (in *Interpreter) alloc(n int64) *object.Error {
+	in.allocated += n
+	if in.limits.MaxAlloc > 0 && in.allocated > in.limits.MaxAlloc {
+		return limitError(ErrMaxAlloc)
+	}
+
+	return nil
+}
+
+// track accounts for a newly created object, returning an error in its place
+// once the allocation budget is exhausted.
+func (in *Interpreter) track(obj object.Object) object.Object {
+	if err := in.alloc(sizeOf(obj)); err != nil {
+		return err
+	}
+
+	return obj
+}
@@ -0,0 +1,12 @@
+package evaluator
+
+import "inter/object"
+
+// Native is a builtin needing the interpreter calling it, for example to
+// read its clock. To scripts it is an ordinary builtin.
+type Native struct {
+	Fn func(in *Interpreter, args ...object.Object) object.Object
+}
+
+func (n *Native) Type() object.ObjectType { return object.BUILTIN_OBJ }
+func (n *Native) Inspect() string         { return "builtin function" }
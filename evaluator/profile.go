@@ -0,0 +1,57 @@
+package evaluator
+
+import (
+	"inter/ast"
+	"inter/object"
+	"sync/atomic"
+	"time"
+)
+
+// Sampler receives the call stack of a profiled interpreter, see
+// Interpreter.Profile.
+type Sampler interface {
+	// Sample records that the interpreter spent n sampling periods in
+	// stack, innermost frame first.
+	Sample(stack []object.StackFrame, n int)
+}
+
+// Profile makes the interpreter report its call stack to s once every
+// period of wall-clock time, until stop is called. The stack is taken at
+// the next evaluated node, so time spent in a builtin is charged to the
+// code evaluated after it returns. Profile must not be called during an
+// evaluation.
+func (in *Interpreter) Profile(s Sampler, period time.Duration) (stop func()) {
+	in.sampler = s
+	atomic.StoreInt32(&in.ticks, 0)
+
+	ticker := time.NewTicker(period)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				atomic.AddInt32(&in.ticks, 1)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		in.sampler = nil
+	}
+}
+
+// sample reports the call stack at node if a period elapsed since the last
+// sample. It is called for every evaluated node, so the common case only
+// costs an atomic load.
+func (in *Interpreter) sample(node ast.Node) {
+	if atomic.LoadInt32(&in.ticks) == 0 {
+		return
+	}
+
+	n := atomic.SwapInt32(&in.ticks, 0)
+	in.sampler.Sample(in.stackTrace(node), int(n))
+}
@@ -0,0 +1,144 @@
+package evaluator
+
+import (
+	"inter/object"
+	"regexp"
+	"regexp/syntax"
+)
+
+// reNamespace holds the regular expression functions, using the syntax of
+// Go's regexp package. Functions taking a pattern accept either a string,
+// compiled on each call, or a regexp returned by re.compile.
+func reNamespace() *object.Module {
+	return namespace("re", map[string]object.Object{
+		"compile": &object.Builtin{Fn: func(args ...object.Object) object.Object {
+			if err := arity(args, 1); err != nil {
+				return err
+			}
+			re, err := pattern("compile", args[0])
+			if err != nil {
+				return err
+			}
+			return &object.Regexp{Value: re}
+		}},
+		"match": reBuiltin("match", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			return getBool(re.MatchString(args[0]))
+		}),
+		"find": reBuiltin("find", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			loc := re.FindStringIndex(args[0])
+			if loc == nil {
+				return NULL
+			}
+			return &object.String{Value: args[0][loc[0]:loc[1]]}
+		}),
+		"find_all": reBuiltin("find_all", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			return stringArray(re.FindAllString(args[0], -1))
+		}),
+		"groups": reBuiltin("groups", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			loc := re.FindStringSubmatchIndex(args[0])
+			if loc == nil {
+				return NULL
+			}
+			return submatches(args[0], loc)
+		}),
+		"named": reBuiltin("named", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			loc := re.FindStringSubmatchIndex(args[0])
+			if loc == nil {
+				return NULL
+			}
+			groups := submatches(args[0], loc).Elements
+			pairs := make(map[object.HashKey]object.HashPair)
+			for i, name := range re.SubexpNames() {
+				if name == "" {
+					continue
+				}
+				key := &object.String{Value: name}
+				pairs[key.HashKey()] = object.HashPair{Key: key, Value: groups[i]}
+			}
+			return &object.Hash{Pairs: pairs}
+		}),
+		"replace": reBuiltin("replace", 3, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			// Account for the result before creating it, expanding the
+			// replacement of one match at a time to measure it.
+			size := int64(len(args[0]))
+			var expanded []byte
+			for _, loc := range re.FindAllStringSubmatchIndex(args[0], -1) {
+				expanded = re.ExpandString(expanded[:0], args[1], args[0], loc)
+				size += int64(len(expanded) - (loc[1] - loc[0]))
+			}
+			if err := in.alloc(size); err != nil {
+				return err
+			}
+			return &object.String{Value: re.ReplaceAllString(args[0], args[1])}
+		}),
+		"split": reBuiltin("split", 2, func(in *Interpreter, re *regexp.Regexp, args []string) object.Object {
+			return stringArray(re.Split(args[0], -1))
+		}),
+	})
+}
+
+// reBuiltin returns the function re.name taking a pattern followed by
+// string arguments, n arguments in all.
+func reBuiltin(name string, n int, fn func(in *Interpreter, re *regexp.Regexp, args []string) object.Object) *Native {
+	return &Native{Fn: func(in *Interpreter, args ...object.Object) object.Object {
+		if err := arity(args, n); err != nil {
+			return err
+		}
+		re, err := pattern(name, args[0])
+		if err != nil {
+			return err
+		}
+
+		strs := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			str, ok := arg.(*object.String)
+			if !ok {
+				return newError(object.KindType, "argument %d to `re.%s` must be STRING, got %s", i+2, name, arg.Type())
+			}
+			strs[i] = str.Value
+		}
+		return fn(in, re, strs)
+	}}
+}
+
+// pattern returns the regexp of the pattern argument of re.name.
+func pattern(name string, arg object.Object) (*regexp.Regexp, *object.Error) {
+	switch arg := arg.(type) {
+	case *object.Regexp:
+		return arg.Value, nil
+	case *object.String:
+		re, err := regexp.Compile(arg.Value)
+		if err != nil {
+			msg := err.Error()
+			if serr, ok := err.(*syntax.Error); ok {
+				msg = serr.Code.String()
+			}
+			return nil, newError(object.KindArgument, "invalid pattern %q: %s", arg.Value, msg)
+		}
+		return re, nil
+	default:
+		return nil, newError(object.KindType, "argument 1 to `re.%s` must be STRING or REGEXP, got %s", name, arg.Type())
+	}
+}
+
+// submatches returns the groups of a match located by loc in s, the whole
+// match first. Groups that did not participate in the match are NULL.
+func submatches(s string, loc []int) *object.Array {
+	groups := make([]object.Object, len(loc)/2)
+	for i := range groups {
+		if loc[2*i] < 0 {
+			groups[i] = NULL
+			continue
+		}
+		groups[i] = &object.String{Value: s[loc[2*i]:loc[2*i+1]]}
+	}
+	return &object.Array{Elements: groups}
+}
+
+func stringArray(strs []string) *object.Array {
+	elements := make([]object.Object, len(strs))
+	for i, s := range strs {
+		elements[i] = &object.String{Value: s}
+	}
+	return &object.Array{Elements: elements}
+}
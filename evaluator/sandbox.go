@@ -0,0 +1,157 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"inter/object"
+	"io"
+	"os"
+)
+
+// Capability names a host resource that builtins may use.
+type Capability string
+
+const (
+	CapFS     Capability = "fs"
+	CapTime   Capability = "time"
+	CapRandom Capability = "random"
+	CapEnv    Capability = "env"
+	CapPrint  Capability = "print"
+)
+
+var AllCapabilities = []Capability{CapFS, CapTime, CapRandom, CapEnv, CapPrint}
+
+// Sandbox is an allow-list of capabilities deciding which builtins of an
+// environment are usable. Builtins needing a denied capability are still
+// defined, but calling them returns an error naming the capability.
+type Sandbox struct {
+	allowed map[Capability]bool
+
+	Stdout io.Writer // where puts writes, os.Stdout by default
+	Seed   int64     // the initial seed of math.random and rand, for reproducible runs
+
+	// FS is the file system of the fs namespace and read_file, HostFS if
+	// nil.
+	FS FileSystem
+}
+
+func NewSandbox(allowed ...Capability) *Sandbox {
+	s := &Sandbox{allowed: make(map[Capability]bool), Stdout: os.Stdout}
+	for _, c := range allowed {
+		s.allowed[c] = true
+	}
+	return s
+}
+
+func (s *Sandbox) Allows(c Capability) bool {
+	return s.allowed[c]
+}
+
+// Define binds fn, an *object.Builtin or a *Native, to name in env if the
+// sandbox allows c. Hosts use it to register their own functions under the
+// same rules as the builtins.
+func (s *Sandbox) Define(env *object.Environment, name string, c Capability, fn object.Object) {
+	env.Set(name, s.guard(name, c, fn))
+}
+
+// guard returns fn if the sandbox allows c, and otherwise a builtin
+// failing with an error naming the capability.
+func (s *Sandbox) guard(name string, c Capability, fn object.Object) object.Object {
+	if s.Allows(c) {
+		return fn
+	}
+	return &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return newError(object.KindCapability, "%s: missing capability %q", name, c)
+	}}
+}
+
+func (s *Sandbox) fs() FileSystem {
+	if s.FS == nil {
+		return HostFS{}
+	}
+	return s.FS
+}
+
+// NewEnvironment creates an environment holding the builtins. Each
+// environment has its own generator for math.random and rand, starting
+// from Seed.
+func (s *Sandbox) NewEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+
+	// Builtins without side effects need no capability.
+	env.Set("error", &object.Builtin{Fn: errorValue})
+	for name, fn := range collectionBuiltins() {
+		env.Set(name, fn)
+	}
+	env.Set("strings", stringsNamespace())
+	env.Set("json", jsonNamespace())
+	env.Set("re", reNamespace())
+	env.Set("fs", s.fsNamespace(s.fs()))
+	env.Set("time", s.timeNamespace())
+	r := newLockedRand(s.Seed)
+	env.Set("math", s.mathNamespace(r))
+
+	s.Define(env, "puts", CapPrint, &object.Builtin{Fn: s.puts})
+	s.Define(env, "now", CapTime, &Native{Fn: func(in *Interpreter, args ...object.Object) object.Object {
+		if err := arity(args, 0); err != nil {
+			return err
+		}
+		return &object.Integer{Value: in.Now().Unix()}
+	}})
+	s.Define(env, "rand", CapRandom, mustBuiltin(func(n int64) (int64, error) {
+		if n <= 0 {
+			return 0, errors.New("argument to `rand` must be positive")
+		}
+		return r.int63n(n), nil
+	}))
+	s.Define(env, "getenv", CapEnv, mustBuiltin(func(name string) object.Object {
+		if val, ok := os.LookupEnv(name); ok {
+			return &object.String{Value: val}
+		}
+		return NULL
+	}))
+	s.Define(env, "read_file", CapFS, mustBuiltin(func(path string) (string, error) {
+		content, err := readFile(s.fs(), path)
+		return string(content), err
+	}))
+
+	return env
+}
+
+func (s *Sandbox) puts(args ...object.Object) object.Object {
+	for _, arg := range args {
+		fmt.Fprintln(s.Stdout, arg.Inspect())
+	}
+	return NULL
+}
+
+// errorValue implements error(message, kind), creating an error value
+// scripts can throw. kind defaults to "Error".
+func errorValue(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return newError(object.KindArgument, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+
+	err := &object.Error{Kind: object.KindError}
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return newError(object.KindType, "argument %d to `error` must be STRING, got %s", i+1, arg.Type())
+		}
+		if i == 0 {
+			err.Message = str.Value
+		} else {
+			err.Kind = str.Value
+		}
+	}
+
+	return &object.ErrorValue{Error: err}
+}
+
+func mustBuiltin(fn interface{}) *object.Builtin {
+	b, err := object.NewBuiltin(fn)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,258 @@
+package evaluator
+
+import (
+	"fmt"
+	"inter/object"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// namespace groups builtins and constants under name, so that scripts use
+// them as name.member without adding each of them to the global scope.
+func namespace(name string, members map[string]object.Object) *object.Module {
+	mod := &object.Module{Name: name, Env: object.NewEnvironment()}
+	for member, fn := range members {
+		mod.Env.Set(member, fn)
+		mod.Exports = append(mod.Exports, member)
+	}
+	sort.Strings(mod.Exports)
+	return mod
+}
+
+// stringsNamespace holds the string functions. Lengths, indexes and
+// slices count runes rather than bytes.
+func stringsNamespace() *object.Module {
+	return namespace("strings", map[string]object.Object{
+		"len": mustBuiltin(func(s string) int {
+			return utf8.RuneCountInString(s)
+		}),
+		"slice": mustBuiltin(func(s string, bounds ...int) (string, error) {
+			runes := []rune(s)
+			start, end, err := sliceBounds(len(runes), bounds)
+			if err != nil {
+				return "", err
+			}
+			return string(runes[start:end]), nil
+		}),
+		"split": mustBuiltin(func(s, sep string) []string {
+			return strings.Split(s, sep)
+		}),
+		"join": &Native{Fn: join},
+		"trim": mustBuiltin(func(s string, cutset ...string) (string, error) {
+			switch len(cutset) {
+			case 0:
+				return strings.TrimSpace(s), nil
+			case 1:
+				return strings.Trim(s, cutset[0]), nil
+			default:
+				return "", fmt.Errorf("wrong number of arguments. got=%d, want=1 or 2", 1+len(cutset))
+			}
+		}),
+		"replace": &Native{Fn: replace},
+		"contains": mustBuiltin(func(s, substr string) bool {
+			return strings.Contains(s, substr)
+		}),
+		"starts_with": mustBuiltin(func(s, prefix string) bool {
+			return strings.HasPrefix(s, prefix)
+		}),
+		"ends_with": mustBuiltin(func(s, suffix string) bool {
+			return strings.HasSuffix(s, suffix)
+		}),
+		"index_of": mustBuiltin(func(s, substr string) int {
+			i := strings.Index(s, substr)
+			if i < 0 {
+				return -1
+			}
+			return utf8.RuneCountInString(s[:i])
+		}),
+		"upper":  mustBuiltin(strings.ToUpper),
+		"lower":  mustBuiltin(strings.ToLower),
+		"repeat": &Native{Fn: repeat},
+		"format": &object.Builtin{Fn: format},
+	})
+}
+
+// stringArguments returns the arguments of the builtin name, which must be
+// n strings.
+func stringArguments(name string, args []object.Object, n int) ([]string, *object.Error) {
+	if err := arity(args, n); err != nil {
+		return nil, err
+	}
+	strs := make([]string, n)
+	for i, arg := range args {
+		str, ok := arg.(*object.String)
+		if !ok {
+			return nil, newError(object.KindType, "argument %d to `%s` must be STRING, got %s", i+1, name, arg.Type())
+		}
+		strs[i] = str.Value
+	}
+	return strs, nil
+}
+
+// join implements strings.join(parts, sep), the strings of the array parts
+// separated by sep.
+func join(in *Interpreter, args ...object.Object) object.Object {
+	if err := arity(args, 2); err != nil {
+		return err
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(object.KindType, "argument 1 to `strings.join` must be ARRAY, got %s", args[0].Type())
+	}
+	sep, ok := args[1].(*object.String)
+	if !ok {
+		return newError(object.KindType, "argument 2 to `strings.join` must be STRING, got %s", args[1].Type())
+	}
+
+	parts := make([]string, len(arr.Elements))
+	size := int64(0)
+	for i, el := range arr.Elements {
+		str, ok := el.(*object.String)
+		if !ok {
+			return newError(object.KindType, "element %d of argument 1 to `strings.join` must be STRING, got %s", i, el.Type())
+		}
+		parts[i] = str.Value
+		size += int64(len(str.Value))
+	}
+	if len(parts) > 1 {
+		size += int64(len(sep.Value)) * int64(len(parts)-1)
+	}
+	// Account for the string before creating it, so that a large join
+	// fails on the memory limit before using the memory.
+	if err := in.alloc(size); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.Join(parts, sep.Value)}
+}
+
+// replace implements strings.replace(s, old, new), s with every old
+// replaced by new.
+func replace(in *Interpreter, args ...object.Object) object.Object {
+	strs, err := stringArguments("strings.replace", args, 3)
+	if err != nil {
+		return err
+	}
+	s, old, new := strs[0], strs[1], strs[2]
+
+	// Account for the string before creating it, so that a large replace
+	// fails on the memory limit before using the memory.
+	n := int64(strings.Count(s, old))
+	if err := in.alloc(int64(len(s)) + n*int64(len(new)-len(old))); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.ReplaceAll(s, old, new)}
+}
+
+// maxRepeat bounds the length in bytes of the strings created by
+// strings.repeat.
+const maxRepeat = 1 << 24
+
+// repeat implements strings.repeat(s, count), s repeated count times.
+func repeat(in *Interpreter, args ...object.Object) object.Object {
+	if err := arity(args, 2); err != nil {
+		return err
+	}
+	s, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.KindType, "argument 1 to `strings.repeat` must be STRING, got %s", args[0].Type())
+	}
+	count, ok := args[1].(*object.Integer)
+	if !ok {
+		return newError(object.KindType, "argument 2 to `strings.repeat` must be INTEGER, got %s", args[1].Type())
+	}
+
+	if count.Value < 0 {
+		return newError(object.KindArgument, "argument to `strings.repeat` must not be negative")
+	}
+	// Dividing rather than multiplying keeps the check from overflowing.
+	if len(s.Value) > 0 && count.Value > maxRepeat/int64(len(s.Value)) {
+		return newError(object.KindArgument, "string of %d times %d bytes is too large", count.Value, len(s.Value))
+	}
+	// Account for the string before creating it, so that a large repeat
+	// fails on the memory limit before using the memory.
+	if err := in.alloc(int64(len(s.Value)) * count.Value); err != nil {
+		return err
+	}
+
+	return &object.String{Value: strings.Repeat(s.Value, int(count.Value))}
+}
+
+// sliceBounds returns the start and end of a slice of a sequence of n
+// elements, from the optional start and end arguments of a builtin.
+func sliceBounds(n int, bounds []int) (int, int, error) {
+	start, end := 0, n
+	switch len(bounds) {
+	case 2:
+		end = bounds[1]
+		fallthrough
+	case 1:
+		start = bounds[0]
+	case 0:
+	default:
+		return 0, 0, fmt.Errorf("wrong number of arguments. got=%d, want=1 to 3", 1+len(bounds))
+	}
+
+	if start < 0 || end < start || end > n {
+		return 0, 0, fmt.Errorf("slice bounds out of range [%d:%d] with length %d", start, end, n)
+	}
+	return start, end, nil
+}
+
+// format implements strings.format(template, args...). %d formats an
+// integer, %s any value as puts would print it and %% is a percent sign.
+func format(args ...object.Object) object.Object {
+	if len(args) == 0 {
+		return newError(object.KindArgument, "wrong number of arguments. got=0, want at least 1")
+	}
+	tmpl, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.KindType, "argument 1 to `strings.format` must be STRING, got %s", args[0].Type())
+	}
+
+	var out strings.Builder
+	values := args[1:]
+	next := 0
+	for i := 0; i < len(tmpl.Value); i++ {
+		c := tmpl.Value[i]
+		if c != '%' {
+			out.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(tmpl.Value) {
+			return newError(object.KindArgument, "format ends with %%")
+		}
+		verb := tmpl.Value[i]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(values) {
+			return newError(object.KindArgument, "missing argument for %%%c", verb)
+		}
+
+		val := values[next]
+		next++
+		switch verb {
+		case 'd':
+			n, ok := val.(*object.Integer)
+			if !ok {
+				return newError(object.KindType, "%%d expects INTEGER, got %s", val.Type())
+			}
+			fmt.Fprintf(&out, "%d", n.Value)
+		case 's':
+			out.WriteString(val.Inspect())
+		default:
+			return newError(object.KindArgument, "unknown verb %%%c", verb)
+		}
+	}
+
+	if next < len(values) {
+		return newError(object.KindArgument, "too many arguments for format: got %d, used %d", len(values), next)
+	}
+	return &object.String{Value: out.String()}
+}
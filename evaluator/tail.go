@@ -0,0 +1,20 @@
+package evaluator
+
+import (
+	"inter/ast"
+	"inter/object"
+)
+
+// tailCall is the value of a call in tail position, see ast.MarkTailCalls.
+// Instead of nesting another evaluation on the Go stack, the call is
+// returned to applyScriptFunction, which makes it in the frame of the
+// function that returned it. Scripts never see a tailCall.
+type tailCall struct {
+	call  *ast.CallExpression
+	fn    *object.Function
+	args  []object.Object
+	named []namedArgument
+}
+
+func (tc *tailCall) Type() object.ObjectType { return "TAIL_CALL" }
+func (tc *tailCall) Inspect() string         { return "tail call to " + tc.fn.Signature() }
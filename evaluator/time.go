@@ -0,0 +1,204 @@
+package evaluator
+
+import (
+	"fmt"
+	"inter/object"
+	"time"
+)
+
+// SetClock makes now the clock of the scripts the interpreter evaluates,
+// for example a fixed time in tests. The clock is time.Now by default.
+func (in *Interpreter) SetClock(now func() time.Time) {
+	in.clock = now
+}
+
+// Now returns the current time according to the clock of the interpreter.
+func (in *Interpreter) Now() time.Time {
+	if in.clock == nil {
+		return time.Now()
+	}
+	return in.clock()
+}
+
+// timeNamespace holds the functions on times and durations. Only now
+// needs CapTime, the other functions are deterministic. Layouts are the
+// ones of Go's time package, written as the reference time
+// "2006-01-02 15:04:05". Times and durations are combined with the
+// arithmetic and comparison operators.
+func (s *Sandbox) timeNamespace() *object.Module {
+	return namespace("time", map[string]object.Object{
+		"now": s.guard("time.now", CapTime, &Native{Fn: func(in *Interpreter, args ...object.Object) object.Object {
+			if err := arity(args, 0); err != nil {
+				return err
+			}
+			return &object.Time{Value: in.Now()}
+		}}),
+
+		"nanosecond":  &object.Duration{Value: time.Nanosecond},
+		"millisecond": &object.Duration{Value: time.Millisecond},
+		"second":      &object.Duration{Value: time.Second},
+		"minute":      &object.Duration{Value: time.Minute},
+		"hour":        &object.Duration{Value: time.Hour},
+
+		"rfc3339":   &object.String{Value: time.RFC3339},
+		"date_only": &object.String{Value: "2006-01-02"},
+		"time_only": &object.String{Value: "15:04:05"},
+		"date_time": &object.String{Value: "2006-01-02 15:04:05"},
+
+		"date": mustBuiltin(func(year, month, day int, clock ...int) (time.Time, error) {
+			if len(clock) > 3 {
+				return time.Time{}, fmt.Errorf("wrong number of arguments. got=%d, want=3 to 6", 3+len(clock))
+			}
+			hms := make([]int, 3)
+			copy(hms, clock)
+			return time.Date(year, time.Month(month), day, hms[0], hms[1], hms[2], 0, time.UTC), nil
+		}),
+		"unix": mustBuiltin(func(sec int64) time.Time {
+			return time.Unix(sec, 0).UTC()
+		}),
+		"parse": mustBuiltin(func(layout, value string) (time.Time, error) {
+			return time.Parse(layout, value)
+		}),
+		"format": mustBuiltin(func(t time.Time, layout string) string {
+			return t.Format(layout)
+		}),
+		"duration": mustBuiltin(func(s string) (time.Duration, error) {
+			return time.ParseDuration(s)
+		}),
+		"add_date": mustBuiltin(func(t time.Time, years, months, days int) time.Time {
+			return t.AddDate(years, months, days)
+		}),
+		"truncate": mustBuiltin(func(t time.Time, d time.Duration) time.Time {
+			return t.Truncate(d)
+		}),
+	})
+}
+
+// evalInfixExpressionForTimes evaluates the operators on times and
+// durations, and reports false if neither operand is one.
+func evalInfixExpressionForTimes(operator string, left object.Object, right object.Object) (object.Object, bool) {
+	switch l := left.(type) {
+	case *object.Time:
+		switch r := right.(type) {
+		case *object.Time:
+			switch operator {
+			case "-":
+				return &object.Duration{Value: l.Value.Sub(r.Value)}, true
+			case "==":
+				return getBool(l.Value.Equal(r.Value)), true
+			case "!=":
+				return getBool(!l.Value.Equal(r.Value)), true
+			case "<":
+				return getBool(l.Value.Before(r.Value)), true
+			case ">":
+				return getBool(l.Value.After(r.Value)), true
+			}
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Time{Value: l.Value.Add(r.Value)}, true
+			case "-":
+				return &object.Time{Value: l.Value.Add(-r.Value)}, true
+			}
+		}
+
+	case *object.Duration:
+		switch r := right.(type) {
+		case *object.Duration:
+			switch operator {
+			case "+":
+				return &object.Duration{Value: l.Value + r.Value}, true
+			case "-":
+				return &object.Duration{Value: l.Value - r.Value}, true
+			case "==":
+				return getBool(l.Value == r.Value), true
+			case "!=":
+				return getBool(l.Value != r.Value), true
+			case "<":
+				return getBool(l.Value < r.Value), true
+			case ">":
+				return getBool(l.Value > r.Value), true
+			}
+		case *object.Time:
+			if operator == "+" {
+				return &object.Time{Value: r.Value.Add(l.Value)}, true
+			}
+		case *object.Integer:
+			switch operator {
+			case "*":
+				return mulDuration(l.Value, r.Value), true
+			case "/":
+				if r.Value == 0 {
+					return newError(object.KindZeroDivision, "division by zero"), true
+				}
+				return &object.Duration{Value: l.Value / time.Duration(r.Value)}, true
+			}
+		}
+
+	case *object.Integer:
+		if r, ok := right.(*object.Duration); ok && operator == "*" {
+			return mulDuration(r.Value, l.Value), true
+		}
+	}
+
+	if left.Type() == object.TIME_OBJ || left.Type() == object.DURATION_OBJ ||
+		right.Type() == object.TIME_OBJ || right.Type() == object.DURATION_OBJ {
+		return newError(object.KindType, "unknown operator: %s %s %s", left.Type(), operator, right.Type()), true
+	}
+	return nil, false
+}
+
+// mulDuration returns the duration d*n, or an error if it overflows.
+func mulDuration(d time.Duration, n int64) object.Object {
+	res := int64(d)
+	if !mulInt(&res, n) {
+		return newError(object.KindArgument, "duration overflow in `*`")
+	}
+	return &object.Duration{Value: time.Duration(res)}
+}
+
+// evalTimeField returns the field name of t, such as t.year or t.weekday.
+// Fields are in the location of t, UTC for the times created by scripts.
+func (in *Interpreter) evalTimeField(t time.Time, name string) object.Object {
+	var val object.Object
+	switch name {
+	case "year":
+		val = &object.Integer{Value: int64(t.Year())}
+	case "month":
+		val = &object.Integer{Value: int64(t.Month())}
+	case "day":
+		val = &object.Integer{Value: int64(t.Day())}
+	case "hour":
+		val = &object.Integer{Value: int64(t.Hour())}
+	case "minute":
+		val = &object.Integer{Value: int64(t.Minute())}
+	case "second":
+		val = &object.Integer{Value: int64(t.Second())}
+	case "weekday":
+		val = &object.String{Value: t.Weekday().String()}
+	case "unix":
+		val = &object.Integer{Value: t.Unix()}
+	default:
+		return newError(object.KindName, "unknown field %s of TIME", name)
+	}
+	return in.track(val)
+}
+
+// evalDurationField returns the field name of d: its length in hours,
+// minutes or seconds as a float, or in milliseconds as an integer.
+func (in *Interpreter) evalDurationField(d time.Duration, name string) object.Object {
+	var val object.Object
+	switch name {
+	case "hours":
+		val = &object.Float{Value: d.Hours()}
+	case "minutes":
+		val = &object.Float{Value: d.Minutes()}
+	case "seconds":
+		val = &object.Float{Value: d.Seconds()}
+	case "milliseconds":
+		val = &object.Integer{Value: d.Milliseconds()}
+	default:
+		return newError(object.KindName, "unknown field %s of DURATION", name)
+	}
+	return in.track(val)
+}
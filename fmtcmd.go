@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"inter/format"
+	"io"
+	"os"
+)
+
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write result to the source files instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: inter fmt [-w] [files...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err == nil {
+			err = formatFile("<stdin>", src, false)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err == nil {
+			err = formatFile(path, src, *write)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func formatFile(path string, src []byte, write bool) error {
+	res, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	if !write {
+		_, err = os.Stdout.Write(res)
+		return err
+	}
+
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	return os.WriteFile(path, res, 0644)
+}
@@ -0,0 +1,507 @@
+// Package format implements the canonical source format of the language.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"inter/ast"
+	"inter/lexer"
+	"inter/parser"
+	"inter/token"
+	"io"
+	"strings"
+)
+
+const indentation = "  "
+
+// Source formats src in the canonical style. Comments are kept in place,
+// blank lines between statements are collapsed into one, and parentheses
+// are only kept where precedence requires them. Formatting its own output
+// is a no-op.
+func Source(src []byte) ([]byte, error) {
+	l := lexer.New(string(src))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return nil, errors.New(strings.Join(msgs, "\n"))
+	}
+
+	pr := &printer{comments: l.Comments(), blank: blankLines(src)}
+	pr.statements(program.Statements, -1)
+	return pr.buf.Bytes(), nil
+}
+
+// Node writes the canonical form of node to w. Comments are not part of the
+// tree, so none are written.
+func Node(w io.Writer, node ast.Node) error {
+	pr := &printer{}
+
+	switch n := node.(type) {
+	case *ast.Program:
+		pr.statements(n.Statements, -1)
+	case ast.Statement:
+		pr.statement(n, nil, false)
+	case ast.Expression:
+		pr.expression(n, parser.LOWEST)
+	}
+
+	_, err := w.Write(pr.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	buf      bytes.Buffer
+	indent   int
+	comments []lexer.Comment
+
+	// lastLine is the source line of what was printed last, used to keep
+	// blank lines. 0 before anything is printed at the current level.
+	lastLine int
+
+	blank []bool // whether each source line is blank, indexed from 1
+}
+
+// blankLines reports which lines of src are blank, indexed from 1.
+func blankLines(src []byte) []bool {
+	lines := bytes.Split(src, []byte("\n"))
+	blank := make([]bool, len(lines)+1)
+	for i, line := range lines {
+		blank[i+1] = len(bytes.TrimSpace(line)) == 0
+	}
+	return blank
+}
+
+func (p *printer) writeIndent() {
+	p.buf.WriteString(strings.Repeat(indentation, p.indent))
+}
+
+// flushComments prints the comments placed before line, or all of them if
+// line is negative.
+func (p *printer) flushComments(line int) {
+	for len(p.comments) > 0 && (line < 0 || p.comments[0].Line < line) {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		p.separate(c.Line)
+		p.writeIndent()
+		p.buf.WriteString(c.Text)
+		p.buf.WriteByte('\n')
+		p.lastLine = c.Line
+	}
+}
+
+// separate keeps one blank line if the source had any between what was
+// printed last and line.
+func (p *printer) separate(line int) {
+	if p.lastLine == 0 {
+		return
+	}
+	for l := p.lastLine + 1; l < line && l < len(p.blank); l++ {
+		if p.blank[l] {
+			p.buf.WriteByte('\n')
+			return
+		}
+	}
+}
+
+// statements prints a list of statements, followed by the comments placed
+// before endLine. The list is a block body if endLine is not negative.
+func (p *printer) statements(list []ast.Statement, endLine int) {
+	for i, st := range list {
+		p.flushComments(startLine(st))
+		p.separate(startLine(st))
+
+		var next ast.Statement
+		if i+1 < len(list) {
+			next = list[i+1]
+		}
+		isValue := endLine >= 0 && next == nil
+
+		p.writeIndent()
+		p.statement(st, next, isValue)
+
+		p.lastLine = endLineOf(st)
+		if len(p.comments) > 0 && p.comments[0].Line == p.lastLine {
+			p.buf.WriteString(" " + p.comments[0].Text)
+			p.comments = p.comments[1:]
+		}
+		p.buf.WriteByte('\n')
+	}
+
+	p.flushComments(endLine)
+}
+
+// statement prints st without indentation or line break. next is the
+// statement following st, if any, and isValue reports whether st gives the
+// value of its block.
+func (p *printer) statement(st ast.Statement, next ast.Statement, isValue bool) {
+	switch st := st.(type) {
+	case *ast.LetStatement:
+		p.buf.WriteString("let " + st.Name.Value + " = ")
+		p.expression(st.Value, parser.LOWEST)
+		p.buf.WriteByte(';')
+
+	case *ast.ReturnStatement:
+		p.buf.WriteString("return")
+		if st.ReturnValue != nil {
+			p.buf.WriteByte(' ')
+			p.expression(st.ReturnValue, parser.LOWEST)
+		}
+		p.buf.WriteByte(';')
+
+	case *ast.ThrowStatement:
+		p.buf.WriteString("throw ")
+		p.expression(st.Value, parser.LOWEST)
+		p.buf.WriteByte(';')
+
+	case *ast.ImportStatement:
+		p.buf.WriteString("import ")
+		if st.Alias != nil {
+			p.buf.WriteString(st.Alias.Value + " from ")
+		}
+		p.buf.WriteString(Quote(st.Path.Value) + ";")
+
+	case *ast.ExpressionStatement:
+		p.expression(st.Expression, parser.LOWEST)
+		if !isValue && needsSemicolon(st, next) {
+			p.buf.WriteByte(';')
+		}
+
+	case *ast.BlockStatement:
+		p.block(st)
+	}
+}
+
+// needsSemicolon reports whether st must be terminated so that next is not
+// parsed as its continuation. Statements ending with a block are only
+// terminated when that could happen, which depends on how next is printed.
+func needsSemicolon(st *ast.ExpressionStatement, next ast.Statement) bool {
+	switch st.Expression.(type) {
+	case *ast.IfExpression, *ast.TryExpression:
+		if next == nil {
+			return false
+		}
+
+		var buf bytes.Buffer
+		Node(&buf, next)
+		first := lexer.New(buf.String()).NextToken()
+		return parser.Precedence(first.Type) > parser.LOWEST
+	default:
+		return true
+	}
+}
+
+func (p *printer) block(bs *ast.BlockStatement) {
+	endLine := bs.EndToken.Line
+	if len(bs.Statements) == 0 && (len(p.comments) == 0 || p.comments[0].Line >= endLine) {
+		p.buf.WriteString("{}")
+		return
+	}
+
+	p.buf.WriteString("{\n")
+	p.indent++
+	lastLine := p.lastLine
+	p.lastLine = 0
+
+	p.statements(bs.Statements, endLine)
+
+	p.indent--
+	p.lastLine = lastLine
+	p.writeIndent()
+	p.buf.WriteByte('}')
+}
+
+// precedence reports how tightly exp binds, parenthesized expressions are
+// the tightest.
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(exp.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.MemberExpression:
+		return parser.INDEX
+	default:
+		return parser.INDEX + 1
+	}
+}
+
+// expression prints exp, parenthesized if it binds less tightly than prec.
+func (p *printer) expression(exp ast.Expression, prec int) {
+	if precedence(exp) < prec {
+		p.buf.WriteByte('(')
+		defer p.buf.WriteByte(')')
+	}
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.buf.WriteString(exp.Value)
+
+	case *ast.IntegerLiteral:
+		p.buf.WriteString(exp.Token.Literal)
+
+	case *ast.FloatLiteral:
+		p.buf.WriteString(exp.Token.Literal)
+
+	case *ast.BooleanLiteral:
+		p.buf.WriteString(exp.Token.Literal)
+
+	case *ast.StringLiteral:
+		p.buf.WriteString(Quote(exp.Value))
+
+	case *ast.PrefixExpression:
+		p.buf.WriteString(exp.Operator)
+		p.expression(exp.Right, parser.PREFIX)
+
+	case *ast.InfixExpression:
+		// Operators are left associative, so only the right operand needs
+		// parentheses at the same precedence.
+		prec := precedence(exp)
+		p.expression(exp.Left, prec)
+		p.buf.WriteString(" " + exp.Operator)
+		p.space(exp.Right, endLineOf(exp.Left))
+		p.expression(exp.Right, prec+1)
+
+	case *ast.IfExpression:
+		p.buf.WriteString("if (")
+		p.expression(exp.Condition, parser.LOWEST)
+		p.buf.WriteString(") ")
+		p.block(exp.Body)
+		if exp.ElseBody != nil {
+			p.buf.WriteString(" else ")
+			p.block(exp.ElseBody)
+		}
+
+	case *ast.TryExpression:
+		p.buf.WriteString("try ")
+		p.block(exp.Body)
+		if exp.CatchBody != nil {
+			p.buf.WriteString(" catch (" + exp.CatchParam.Value + ") ")
+			p.block(exp.CatchBody)
+		}
+		if exp.FinallyBody != nil {
+			p.buf.WriteString(" finally ")
+			p.block(exp.FinallyBody)
+		}
+
+	case *ast.FunctionLiteral:
+		p.buf.WriteString("fn(")
+		for i, param := range exp.FunctionParameters {
+			if i > 0 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString(param.Value)
+			if def := exp.Default(i); def != nil {
+				p.buf.WriteString(" = ")
+				p.expression(def, parser.LOWEST)
+			}
+		}
+		if exp.Rest != nil {
+			if len(exp.FunctionParameters) > 0 {
+				p.buf.WriteString(", ")
+			}
+			p.buf.WriteString("..." + exp.Rest.Value)
+		}
+		p.buf.WriteString(") ")
+		p.block(exp.FunctionBody)
+
+	case *ast.CallExpression:
+		p.expression(exp.Function, parser.CALL)
+		p.buf.WriteByte('(')
+		p.expressionList(exp.Arguments, exp.Token.Line)
+		p.buf.WriteByte(')')
+
+	case *ast.SpreadExpression:
+		p.buf.WriteString("...")
+		p.expression(exp.Value, parser.LOWEST)
+
+	case *ast.NamedArgument:
+		p.buf.WriteString(exp.Name.Value + ": ")
+		p.expression(exp.Value, parser.LOWEST)
+
+	case *ast.ArrayLiteral:
+		p.buf.WriteByte('[')
+		p.expressionList(exp.Elements, exp.Token.Line)
+		p.buf.WriteByte(']')
+
+	case *ast.IndexExpression:
+		p.expression(exp.Left, parser.CALL)
+		p.buf.WriteByte('[')
+		p.expression(exp.Index, parser.LOWEST)
+		p.buf.WriteByte(']')
+
+	case *ast.MemberExpression:
+		p.expression(exp.Left, parser.CALL)
+		p.buf.WriteString("." + exp.Property.Value)
+
+	case *ast.HashLiteral:
+		p.buf.WriteByte('{')
+		line := exp.Token.Line
+		for i, key := range exp.Keys {
+			if i > 0 {
+				p.buf.WriteByte(',')
+				p.space(key, line)
+			} else {
+				p.lineComments(ast.StartToken(key).Line, line)
+			}
+			p.expression(key, parser.LOWEST)
+			p.buf.WriteByte(':')
+			p.space(exp.Pairs[key], endLineOf(key))
+			p.expression(exp.Pairs[key], parser.LOWEST)
+			line = endLineOf(exp.Pairs[key])
+		}
+		p.buf.WriteByte('}')
+	}
+}
+
+// expressionList prints the elements of a list opened on line.
+func (p *printer) expressionList(list []ast.Expression, line int) {
+	for i, exp := range list {
+		if i > 0 {
+			p.buf.WriteByte(',')
+			p.space(exp, line)
+		} else {
+			p.lineComments(ast.StartToken(exp).Line, line)
+		}
+		p.expression(exp, parser.LOWEST)
+		line = endLineOf(exp)
+	}
+}
+
+// space separates exp from what precedes it, which ended on line: by the
+// comments placed in between, if any, and by a space otherwise.
+func (p *printer) space(exp ast.Expression, line int) {
+	if !p.lineComments(ast.StartToken(exp).Line, line) {
+		p.buf.WriteByte(' ')
+	}
+}
+
+// lineComments prints the comments placed inside an expression before
+// line, where they are, and reports whether there were any. A comment on
+// line after, the line of what was printed last, stays on it, and the
+// others get their own line. The expression continues on a new line,
+// indented one level deeper than its statement.
+func (p *printer) lineComments(line, after int) bool {
+	if len(p.comments) == 0 || p.comments[0].Line >= line {
+		return false
+	}
+
+	for len(p.comments) > 0 && p.comments[0].Line < line {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if c.Line > after {
+			p.continueLine()
+		} else {
+			p.buf.WriteByte(' ')
+		}
+		p.buf.WriteString(c.Text)
+		after = c.Line
+	}
+	p.continueLine()
+	return true
+}
+
+// continueLine breaks the line inside an expression.
+func (p *printer) continueLine() {
+	p.buf.WriteByte('\n')
+	p.writeIndent()
+	p.buf.WriteString(indentation)
+}
+
+// Quote returns s as a string literal.
+func Quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\':
+			out.WriteByte('\\')
+			out.WriteByte(c)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+func startLine(st ast.Statement) int {
+	return ast.StartToken(st).Line
+}
+
+// endLineOf returns the last source line spanned by node, as far as the
+// tokens kept in the tree tell.
+func endLineOf(node ast.Node) int {
+	line := 0
+	ast.Inspect(node, func(n ast.Node) bool {
+		var tok token.Token
+		switch n := n.(type) {
+		case *ast.BlockStatement:
+			tok = n.EndToken
+		case *ast.LetStatement:
+			tok = n.Token
+		case *ast.ReturnStatement:
+			tok = n.Token
+		case *ast.ThrowStatement:
+			tok = n.Token
+		case *ast.ImportStatement:
+			tok = n.Token
+		case *ast.ExpressionStatement:
+			tok = n.Token
+		case *ast.Identifier:
+			tok = n.Token
+		case *ast.IntegerLiteral:
+			tok = n.Token
+		case *ast.FloatLiteral:
+			tok = n.Token
+		case *ast.BooleanLiteral:
+			tok = n.Token
+		case *ast.StringLiteral:
+			tok = n.Token
+		case *ast.PrefixExpression:
+			tok = n.Token
+		case *ast.InfixExpression:
+			tok = n.Token
+		case *ast.IfExpression:
+			tok = n.Token
+		case *ast.TryExpression:
+			tok = n.Token
+		case *ast.FunctionLiteral:
+			tok = n.Token
+		case *ast.CallExpression:
+			tok = n.Token
+		case *ast.SpreadExpression:
+			tok = n.Token
+		case *ast.NamedArgument:
+			tok = n.Token
+		case *ast.ArrayLiteral:
+			tok = n.Token
+		case *ast.IndexExpression:
+			tok = n.Token
+		case *ast.MemberExpression:
+			tok = n.Token
+		case *ast.HashLiteral:
+			tok = n.Token
+		}
+
+		if tok.Line > line {
+			line = tok.Line
+		}
+		return true
+	})
+
+	return line
+}
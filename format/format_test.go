@@ -0,0 +1,136 @@
+package format
+
+import (
+	"bytes"
+	"inter/lexer"
+	"inter/parser"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); (1 + 2) + 3; 1 - (2 - 3)", "(1 + 2) * 3;\n1 + 2 * 3;\n1 + 2 + 3;\n1 - (2 - 3);\n"},
+		{"-(a * b); !(-a); (-a) * b", "-(a * b);\n!-a;\n-a * b;\n"},
+		{"(f)(x)[0]; (a + b)[1]; (fn(x){x})(1)", "f(x)[0];\n(a + b)[1];\nfn(x) {\n  x\n}(1);\n"},
+		{`["a\"b", {"k": [1,2]}]`, `["a\"b", {"k": [1, 2]}];` + "\n"},
+		{"fn() {}", "fn() {};\n"},
+		{
+			"if (a) { b } else { c } let d = 1;",
+			"if (a) {\n  b\n} else {\n  c\n}\nlet d = 1;\n",
+		},
+		{
+			"let f = fn(x, y) { let z = x; return z + y; }",
+			"let f = fn(x, y) {\n  let z = x;\n  return z + y;\n};\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"// head\n\nlet a = 1; // one\n// before b\nlet b = fn() {\n// inside\n  1 // value\n  // end\n};\n// tail",
+			"// head\n\nlet a = 1; // one\n// before b\nlet b = fn() {\n  // inside\n  1 // value\n  // end\n};\n// tail\n",
+		},
+		{"if (x) { // why\n}", "if (x) {\n  // why\n}\n"},
+		{"[1, // one\n 2]", "[1, // one\n  2];\n"},
+		{
+			"let xs = [\n  // first\n  1,\n  // second\n  2\n];\nlet y = 1;",
+			"let xs = [\n  // first\n  1,\n  // second\n  2];\nlet y = 1;\n",
+		},
+		{
+			"let f = fn() {\n  f(1 + // why\n2, {\"a\": 1, // a\n\"b\": 2})\n};",
+			"let f = fn() {\n  f(1 + // why\n    2, {\"a\": 1, // a\n    \"b\": 2})\n};\n",
+		},
+		{
+			"try { f() } catch (e) { throw e; } finally { g() }; (h)(); try { 1 } finally {}; -1",
+			"try {\n  f()\n} catch (e) {\n  throw e;\n} finally {\n  g()\n}\nh();\ntry {\n  1\n} finally {};\n-1;\n",
+		},
+		{"(a.b).c; (a + b).c; (f(x)).y[0]", "a.b.c;\n(a + b).c;\nf(x).y[0];\n"},
+		{`import "lib/x.mk"
+import   y   from "./y"`, "import \"lib/x.mk\";\nimport y from \"./y\";\n"},
+	}
+
+	for _, tt := range tests {
+		res, err := Source([]byte(tt.input))
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", tt.input, err)
+			continue
+		}
+
+		if string(res) != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=%q\ngot=     %q", tt.input, tt.expected, res)
+		}
+	}
+}
+
+func TestSourceErrors(t *testing.T) {
+	if _, err := Source([]byte("let = 5")); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
+
+// TestRoundTrip checks that formatting does not change the meaning of a
+// program, and that formatted code is left alone by another pass.
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(10)",
+		"a + b * c - (d - e) / f; -(-a); !!true; a * (b + c) * d",
+		"5 > 4 == 3 < 4; (5 > 4) == (3 < 4); a == (b == c)",
+		"let h = {\"one\": 1, true: [1, 2][0]}; h[\"one\"] + add(1, 2 * 3)(4)",
+		"if (a) { 1 } else { if (b) { 2 } }; (if (c) { f } else { g })(1)",
+		"if (x) { 1 }\n-1;\nif (y) { 2 } let z = 3;",
+		"let f = fn(x) { fn(y) { x + y } }; f(1)(2); // adder",
+		"let r = try { f(1) } catch (err) { err.message } finally { g() }; -r.kind",
+		"try { throw error(\"x\"); } finally { 1 }\n[1][0];",
+		"let r = 1.50 * -math.pi + 2.0 / 3",
+		"let f = fn(a, b = 1 + 2, ...rest) { [a, b, rest] }; f(...[1, 2], 3); f(1, b: -2)",
+		"let xs = [1, // one\n  // two\n  2, {\"k\": // key\n  3}];\n\nf(xs, // args\n  4)",
+	}
+
+	for _, input := range inputs {
+		first, err := Source([]byte(input))
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", input, err)
+			continue
+		}
+
+		if parse(t, input) != parse(t, string(first)) {
+			t.Errorf("formatting changed the program.\ninput=%q\nformatted=%q", input, first)
+		}
+
+		second, err := Source(first)
+		if err != nil {
+			t.Errorf("Source(%q) failed: %v", first, err)
+			continue
+		}
+
+		if !bytes.Equal(first, second) {
+			t.Errorf("formatting is not idempotent.\nfirst= %q\nsecond=%q", first, second)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	program := parser.New(lexer.New("let x = (1 + 2) * y;")).ParseProgram()
+
+	var buf bytes.Buffer
+	if err := Node(&buf, program.Statements[0]); err != nil {
+		t.Fatalf("Node failed: %v", err)
+	}
+
+	if buf.String() != "let x = (1 + 2) * y;" {
+		t.Errorf("wrong output. got=%q", buf.String())
+	}
+}
+
+func parse(t *testing.T, input string) string {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parse errors for %q: %v", input, p.Errors())
+	}
+	return program.String()
+}
@@ -2,22 +2,46 @@ package lexer
 
 import (
 	"inter/token"
+	"strings"
 )
 
+// Comment is a // comment, which the parser never sees. The lexer keeps them
+// aside for tools that reproduce the source.
+type Comment struct {
+	Text   string // including the leading //
+	Line   int
+	Column int
+}
+
 type Lexer struct {
 	input        string
 	position     int
 	readPosition int
 	ch           byte
+
+	line     int
+	column   int
+	comments []Comment
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// Comments returns the comments skipped so far, in source order.
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line += 1
+		l.column = 0
+	}
+	l.column += 1
+
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -43,25 +67,91 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[pos:l.position]
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer, or a float if the digits are followed by a
+// dot and more digits. A dot followed by anything else is left for member
+// access.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	pos := l.position
 	for isNumber(l.ch) {
 		l.readChar()
 	}
-	return l.input[pos:l.position]
+	if l.ch != '.' || !isNumber(l.peekChar()) {
+		return l.input[pos:l.position], token.INT
+	}
+
+	l.readChar()
+	for isNumber(l.ch) {
+		l.readChar()
+	}
+	return l.input[pos:l.position], token.FLOAT
 }
 
-func (l *Lexer) skipWhitespaces() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+func (l *Lexer) readString() string {
+	var out strings.Builder
+	for {
 		l.readChar()
+		if l.ch == '"' || l.ch == 0 {
+			break
+		}
+
+		if l.ch == '\\' {
+			l.readChar()
+			switch l.ch {
+			case 'n':
+				out.WriteByte('\n')
+			case 't':
+				out.WriteByte('\t')
+			case 'r':
+				out.WriteByte('\r')
+			case 0:
+				return out.String()
+			default:
+				out.WriteByte(l.ch)
+			}
+			continue
+		}
+
+		out.WriteByte(l.ch)
 	}
+	return out.String()
 }
 
-func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
+func (l *Lexer) readComment() {
+	comment := Comment{Line: l.line, Column: l.column}
+	pos := l.position
+	for l.ch != '\n' && l.ch != 0 {
+		l.readChar()
+	}
+
+	comment.Text = strings.TrimRight(l.input[pos:l.position], "\r")
+	l.comments = append(l.comments, comment)
+}
+
+func (l *Lexer) skipWhitespaces() {
+	for {
+		for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+			l.readChar()
+		}
+
+		if l.ch != '/' || l.peekChar() != '/' {
+			return
+		}
+		l.readComment()
+	}
+}
 
+func (l *Lexer) NextToken() token.Token {
 	l.skipWhitespaces()
 
+	line, column := l.line, l.column
+	tok := l.readToken()
+	tok.Line, tok.Column = line, column
+	return tok
+}
+
+func (l *Lexer) readToken() token.Token {
+	var tok token.Token
+
 	switch l.ch {
 	case '=':
 		if l.peekChar() == '=' {
@@ -86,6 +176,23 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
+	case '[':
+		tok = newToken(token.LBRACKET, l.ch)
+	case ']':
+		tok = newToken(token.RBRACKET, l.ch)
+	case ':':
+		tok = newToken(token.COLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.readPosition+1 < len(l.input) && l.input[l.readPosition+1] == '.' {
+			l.readChar()
+			l.readChar()
+			tok = token.Token{Type: token.ELLIPSIS, Literal: "..."}
+		} else {
+			tok = newToken(token.DOT, l.ch)
+		}
+	case '"':
+		tok.Type = token.STRING
+		tok.Literal = l.readString()
 	case 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
@@ -114,8 +221,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isNumber(l.ch) {
-			tok.Literal = l.readNumber()
-			tok.Type = token.INT
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -131,9 +237,9 @@ func newToken(t token.TokenType, ch byte) token.Token {
 }
 
 func isLetter(ch byte) bool {
-	return ch-'a' <= 26 || ch-'A' <= 26 || ch == '_'
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
 func isNumber(ch byte) bool {
-	return ch-'0' <= 9
+	return '0' <= ch && ch <= '9'
 }
@@ -0,0 +1,117 @@
+package lsp
+
+import (
+	"inter/ast"
+	"inter/lexer"
+	"inter/parser"
+	"inter/scope"
+	"strings"
+)
+
+// document is an open file, parsed and resolved on every change.
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	program *ast.Program
+	errors  []parser.Error
+	info    *scope.Info
+	idents  []*ast.Identifier
+}
+
+func newDocument(uri, text string) *document {
+	p := parser.New(lexer.New(text))
+	d := &document{
+		uri:     uri,
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+		program: p.ParseProgram(),
+		errors:  p.ErrorList(),
+	}
+
+	d.info = scope.Resolve(d.program)
+	ast.Inspect(d.program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			d.idents = append(d.idents, ident)
+		}
+		return true
+	})
+	return d
+}
+
+// position converts a 1-based line and byte column into a protocol position.
+func (d *document) position(line, column int) Position {
+	if line < 1 || line > len(d.lines) {
+		return Position{Line: line - 1}
+	}
+
+	text := d.lines[line-1]
+	if column-1 < len(text) {
+		text = text[:column-1]
+	}
+	return Position{Line: line - 1, Character: utf16Len(text)}
+}
+
+// offset converts a protocol position into a 1-based line and byte column.
+func (d *document) offset(pos Position) (line, column int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, 1
+	}
+
+	text := d.lines[pos.Line]
+	units := 0
+	for i, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, i + 1
+		}
+		units += utf16Units(r)
+	}
+	return pos.Line + 1, len(text) + 1
+}
+
+func (d *document) identRange(ident *ast.Identifier) Range {
+	tok := ident.Token
+	return Range{
+		Start: d.position(tok.Line, tok.Column),
+		End:   d.position(tok.Line, tok.Column+len(ident.Value)),
+	}
+}
+
+func (d *document) location(ident *ast.Identifier) Location {
+	return Location{URI: d.uri, Range: d.identRange(ident)}
+}
+
+// identAt returns the identifier under pos, including the position right
+// after its last character, or nil.
+func (d *document) identAt(pos Position) *ast.Identifier {
+	line, column := d.offset(pos)
+	for _, ident := range d.idents {
+		tok := ident.Token
+		if tok.Line == line && tok.Column <= column && column <= tok.Column+len(ident.Value) {
+			return ident
+		}
+	}
+	return nil
+}
+
+// fullRange covers the whole document.
+func (d *document) fullRange() Range {
+	last := len(d.lines) - 1
+	return Range{End: Position{Line: last, Character: utf16Len(d.lines[last])}}
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Units(r)
+	}
+	return n
+}
+
+// utf16Units reports the number of UTF-16 code units encoding r.
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
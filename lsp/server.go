@@ -0,0 +1,357 @@
+// Package lsp implements a Language Server Protocol server for the language.
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"inter/ast"
+	"inter/format"
+	"inter/rpc"
+	"inter/scope"
+	"inter/vet"
+	"io"
+)
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"initialized":                 (*Server).ignore,
+	"shutdown":                    (*Server).shutdown,
+	"textDocument/didOpen":        (*Server).didOpen,
+	"textDocument/didChange":      (*Server).didChange,
+	"textDocument/didClose":       (*Server).didClose,
+	"textDocument/didSave":        (*Server).ignore,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+type Server struct {
+	out  io.Writer
+	docs map[string]*document
+}
+
+// invalidParams marks errors caused by malformed parameters.
+type invalidParams struct{ err error }
+
+func (e invalidParams) Error() string { return e.err.Error() }
+
+// Serve handles the messages read from r, writing responses and
+// notifications to w, until the client sends exit or r is closed.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &Server{out: w, docs: make(map[string]*document)}
+	in := bufio.NewReader(r)
+
+	for {
+		content, err := rpc.ReadMessage(in)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(content, &req); err != nil {
+			s.replyError(nil, codeParseError, err.Error())
+			continue
+		}
+
+		if req.Method == "exit" {
+			return nil
+		}
+
+		if err := s.handle(req); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handle(req request) error {
+	isRequest := len(req.ID) > 0
+
+	h, ok := handlers[req.Method]
+	if !ok {
+		// Unknown notifications, such as $/cancelRequest, are dropped.
+		if isRequest {
+			return s.replyError(req.ID, codeMethodNotFound, "method not found: "+req.Method)
+		}
+		return nil
+	}
+
+	result, err := h(s, req.Params)
+	if !isRequest {
+		return nil
+	}
+
+	if err != nil {
+		code := codeInvalidRequest
+		if errors.As(err, &invalidParams{}) {
+			code = codeInvalidParams
+		}
+		return s.replyError(req.ID, code, err.Error())
+	}
+	return rpc.WriteMessage(s.out, response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, msg string) error {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return rpc.WriteMessage(s.out, errorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   responseError{Code: code, Message: msg},
+	})
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	return rpc.WriteMessage(s.out, notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return invalidParams{err}
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", uri)
+	}
+	return d, nil
+}
+
+func (s *Server) ignore(params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1, // full content on every change
+			"hoverProvider":              true,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+		},
+		"serverInfo": map[string]string{"name": "inter"},
+	}, nil
+}
+
+func (s *Server) shutdown(params json.RawMessage) (interface{}, error) {
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	return nil, s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	return nil, s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update parses the new content of a document and publishes its
+// diagnostics: syntax errors, or the vet warnings once it parses.
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+
+	diagnostics := []Diagnostic{}
+	for _, err := range d.errors {
+		start := d.position(err.Line, err.Column)
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+			Severity: SeverityError,
+			Source:   "parser",
+			Message:  err.Message,
+		})
+	}
+
+	if len(d.errors) == 0 {
+		for _, diag := range vet.Check(d.program) {
+			start := d.position(diag.Line, diag.Column)
+			diagnostics = append(diagnostics, Diagnostic{
+				Range:    Range{Start: start, End: Position{Line: start.Line, Character: start.Character + 1}},
+				Severity: SeverityWarning,
+				Source:   "vet",
+				Message:  diag.Message,
+			})
+		}
+	}
+
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+// bindingAt decodes a position request and returns the binding under it.
+func (s *Server) bindingAt(params json.RawMessage, p *TextDocumentPositionParams) (*document, *ast.Identifier, *scope.Binding, error) {
+	if err := decode(params, p); err != nil {
+		return nil, nil, nil, err
+	}
+
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ident := d.identAt(p.Position)
+	if ident == nil {
+		return d, nil, nil, nil
+	}
+	return d, ident, d.info.BindingAt(ident), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	d, ident, b, err := s.bindingAt(params, &p)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	return Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```\n" + describe(b) + "\n```"},
+		Range:    d.identRange(ident),
+	}, nil
+}
+
+// describe renders the definition of b, only showing the signature of
+// functions.
+func describe(b *scope.Binding) string {
+	switch b.Kind {
+	case scope.Param:
+		return "(parameter) " + b.Name
+	case scope.Catch:
+		return "(catch) " + b.Name
+	case scope.Import:
+		return "import " + b.Name + " from " + format.Quote(b.Value.(*ast.StringLiteral).Value)
+	}
+
+	if fn, ok := b.Value.(*ast.FunctionLiteral); ok {
+		return "let " + b.Name + " = " + signature(fn)
+	}
+
+	var buf bytes.Buffer
+	format.Node(&buf, b.Value)
+	return "let " + b.Name + " = " + buf.String()
+}
+
+func signature(fn *ast.FunctionLiteral) string {
+	return "fn(" + ast.ParameterList(fn.FunctionParameters, fn.Defaults, fn.Rest) + ")"
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	d, _, b, err := s.bindingAt(params, &p)
+	if err != nil || b == nil {
+		return nil, err
+	}
+
+	return d.location(b.Ident), nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, _, b, err := s.bindingAt(params, &p.TextDocumentPositionParams)
+	if err != nil || b == nil {
+		return []Location{}, err
+	}
+
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, d.location(b.Ident))
+	}
+	for _, use := range b.Uses {
+		locations = append(locations, d.location(use))
+	}
+	return locations, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []DocumentSymbol{}
+	for _, b := range d.info.Global.Bindings {
+		symbol := DocumentSymbol{
+			Name:           b.Name,
+			Kind:           SymbolVariable,
+			Range:          d.identRange(b.Ident),
+			SelectionRange: d.identRange(b.Ident),
+		}
+		if fn, ok := b.Value.(*ast.FunctionLiteral); ok {
+			symbol.Kind = SymbolFunction
+			symbol.Detail = signature(fn)
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols, nil
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := format.Source([]byte(d.text))
+	if err != nil {
+		// Nothing to do until the syntax errors are fixed.
+		return []TextEdit{}, nil
+	}
+
+	if string(formatted) == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: d.fullRange(), NewText: string(formatted)}}, nil
+}
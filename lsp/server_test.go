@@ -0,0 +1,272 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"inter/rpc"
+	"io"
+	"strings"
+	"testing"
+)
+
+const uri = "file:///test.mk"
+
+// session runs the server over the given messages, numbering requests from
+// 1, and returns the decoded messages it wrote.
+func session(t *testing.T, messages ...map[string]interface{}) []map[string]interface{} {
+	t.Helper()
+
+	var in bytes.Buffer
+	for _, msg := range messages {
+		msg["jsonrpc"] = "2.0"
+		if err := rpc.WriteMessage(&in, msg); err != nil {
+			t.Fatalf("WriteMessage failed: %s", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Serve(&in, &out); err != nil {
+		t.Fatalf("Serve failed: %s", err)
+	}
+
+	var replies []map[string]interface{}
+	r := bufio.NewReader(&out)
+	for {
+		content, err := rpc.ReadMessage(r)
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %s", err)
+		}
+
+		var reply map[string]interface{}
+		if err := json.Unmarshal(content, &reply); err != nil {
+			t.Fatalf("invalid reply %s: %s", content, err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func open(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"method": "textDocument/didOpen",
+		"params": map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": "inter", "version": 1, "text": text},
+		},
+	}
+}
+
+func call(id int, method string, params map[string]interface{}) map[string]interface{} {
+	params["textDocument"] = map[string]interface{}{"uri": uri}
+	return map[string]interface{}{"id": id, "method": method, "params": params}
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{"position": map[string]interface{}{"line": line, "character": character}}
+}
+
+// result finds the reply to request id and re-encodes its result.
+func result(t *testing.T, replies []map[string]interface{}, id int) string {
+	t.Helper()
+	for _, reply := range replies {
+		if reply["id"] == float64(id) {
+			if e, ok := reply["error"]; ok {
+				t.Fatalf("request %d failed: %v", id, e)
+			}
+			b, _ := json.Marshal(reply["result"])
+			return string(b)
+		}
+	}
+	t.Fatalf("no reply to request %d", id)
+	return ""
+}
+
+func diagnostics(t *testing.T, replies []map[string]interface{}) []string {
+	t.Helper()
+	var messages []string
+	for _, reply := range replies {
+		if reply["method"] != "textDocument/publishDiagnostics" {
+			continue
+		}
+		params := reply["params"].(map[string]interface{})
+		for _, d := range params["diagnostics"].([]interface{}) {
+			d := d.(map[string]interface{})
+			start := d["range"].(map[string]interface{})["start"].(map[string]interface{})
+			b, _ := json.Marshal(start)
+			messages = append(messages, string(b)+" "+d["message"].(string))
+		}
+	}
+	return messages
+}
+
+func TestInitialize(t *testing.T) {
+	replies := session(t,
+		map[string]interface{}{"id": 1, "method": "initialize", "params": map[string]interface{}{}},
+		map[string]interface{}{"method": "initialized", "params": map[string]interface{}{}},
+		map[string]interface{}{"id": 2, "method": "shutdown"},
+		map[string]interface{}{"id": 3, "method": "unknown/method"},
+		map[string]interface{}{"method": "exit"},
+		map[string]interface{}{"id": 4, "method": "shutdown"},
+	)
+
+	if len(replies) != 3 {
+		t.Fatalf("wrong number of replies. got=%d", len(replies))
+	}
+
+	init := result(t, replies, 1)
+	for _, capability := range []string{`"hoverProvider":true`, `"definitionProvider":true`, `"documentFormattingProvider":true`} {
+		if !strings.Contains(init, capability) {
+			t.Errorf("missing capability %s in %s", capability, init)
+		}
+	}
+
+	if got := result(t, replies, 2); got != "null" {
+		t.Errorf("wrong shutdown result. got=%s", got)
+	}
+
+	e, ok := replies[2]["error"].(map[string]interface{})
+	if !ok || e["code"] != float64(codeMethodNotFound) {
+		t.Errorf("expected method not found error. got=%v", replies[2])
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 5;", nil},
+		{"let = 5;", []string{
+			`{"character":4,"line":0} Expected="IDENT", got="="`,
+			`{"character":4,"line":0} no prefix parse function for = found`,
+		}},
+		{"let f = fn(x) { 1 };", []string{`{"character":11,"line":0} parameter x is not used`}},
+		{"let s = \"é\"; let f = fn(y) { 1 };", []string{`{"character":24,"line":0} parameter y is not used`}},
+	}
+
+	for _, tt := range tests {
+		got := diagnostics(t, session(t, open(tt.input)))
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong diagnostics for %q. expected=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestDidChangeAndClose(t *testing.T) {
+	replies := session(t,
+		open("let = 5;"),
+		map[string]interface{}{
+			"method": "textDocument/didChange",
+			"params": map[string]interface{}{
+				"textDocument":   map[string]interface{}{"uri": uri},
+				"contentChanges": []interface{}{map[string]interface{}{"text": "let x = 5;"}},
+			},
+		},
+		map[string]interface{}{
+			"method": "textDocument/didClose",
+			"params": map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}},
+		},
+		call(1, "textDocument/documentSymbol", map[string]interface{}{}),
+	)
+
+	if len(replies) != 4 {
+		t.Fatalf("wrong number of replies. got=%d", len(replies))
+	}
+	for i, expected := range []int{2, 0, 0} {
+		params := replies[i]["params"].(map[string]interface{})
+		if n := len(params["diagnostics"].([]interface{})); n != expected {
+			t.Errorf("wrong number of diagnostics in notification %d. expected=%d, got=%d", i, expected, n)
+		}
+	}
+
+	if _, ok := replies[3]["error"]; !ok {
+		t.Errorf("expected an error for a closed document. got=%v", replies[3])
+	}
+}
+
+const program = `let add = fn(a, b) { a + b };
+let x = add(1, 2);
+add(x, x);
+`
+
+func TestNavigation(t *testing.T) {
+	replies := session(t,
+		open(program),
+		call(1, "textDocument/hover", at(2, 1)),
+		call(2, "textDocument/hover", at(0, 21)),
+		call(3, "textDocument/hover", at(1, 5)),
+		call(4, "textDocument/definition", at(2, 5)),
+		call(5, "textDocument/references", map[string]interface{}{
+			"position": map[string]interface{}{"line": 0, "character": 4},
+			"context":  map[string]interface{}{"includeDeclaration": true},
+		}),
+		call(6, "textDocument/references", map[string]interface{}{
+			"position": map[string]interface{}{"line": 1, "character": 4},
+			"context":  map[string]interface{}{"includeDeclaration": false},
+		}),
+		call(7, "textDocument/hover", at(1, 14)),
+	)
+
+	r := func(line, start, end int) string {
+		return `{"end":{"character":` + itoa(end) + `,"line":` + itoa(line) + `},"start":{"character":` + itoa(start) + `,"line":` + itoa(line) + `}}`
+	}
+
+	tests := []struct {
+		id       int
+		expected string
+	}{
+		{1, `{"contents":{"kind":"markdown","value":"` + "```\\nlet add = fn(a, b)\\n```" + `"},"range":` + r(2, 0, 3) + `}`},
+		{2, `{"contents":{"kind":"markdown","value":"` + "```\\n(parameter) a\\n```" + `"},"range":` + r(0, 21, 22) + `}`},
+		{3, `{"contents":{"kind":"markdown","value":"` + "```\\nlet x = add(1, 2)\\n```" + `"},"range":` + r(1, 4, 5) + `}`},
+		{4, `{"range":` + r(1, 4, 5) + `,"uri":"` + uri + `"}`},
+		{5, `[{"range":` + r(0, 4, 7) + `,"uri":"` + uri + `"},{"range":` + r(1, 8, 11) + `,"uri":"` + uri + `"},{"range":` + r(2, 0, 3) + `,"uri":"` + uri + `"}]`},
+		{6, `[{"range":` + r(2, 4, 5) + `,"uri":"` + uri + `"},{"range":` + r(2, 7, 8) + `,"uri":"` + uri + `"}]`},
+		{7, `null`},
+	}
+
+	for _, tt := range tests {
+		if got := result(t, replies, tt.id); got != tt.expected {
+			t.Errorf("wrong result for request %d.\nexpected=%s\ngot=     %s", tt.id, tt.expected, got)
+		}
+	}
+}
+
+func TestDocumentSymbolAndFormatting(t *testing.T) {
+	replies := session(t,
+		open("let add = fn(a, b) { a + b }\nlet x = add(1,2)"),
+		call(1, "textDocument/documentSymbol", map[string]interface{}{}),
+		call(2, "textDocument/formatting", map[string]interface{}{}),
+	)
+
+	var symbols []DocumentSymbol
+	json.Unmarshal([]byte(result(t, replies, 1)), &symbols)
+	if len(symbols) != 2 {
+		t.Fatalf("wrong number of symbols. got=%d", len(symbols))
+	}
+	if symbols[0].Name != "add" || symbols[0].Kind != SymbolFunction || symbols[0].Detail != "fn(a, b)" {
+		t.Errorf("wrong symbol. got=%+v", symbols[0])
+	}
+	if symbols[1].Name != "x" || symbols[1].Kind != SymbolVariable {
+		t.Errorf("wrong symbol. got=%+v", symbols[1])
+	}
+
+	var edits []TextEdit
+	json.Unmarshal([]byte(result(t, replies, 2)), &edits)
+	if len(edits) != 1 {
+		t.Fatalf("wrong number of edits. got=%d", len(edits))
+	}
+	expected := "let add = fn(a, b) {\n  a + b\n};\nlet x = add(1, 2);\n"
+	if edits[0].NewText != expected {
+		t.Errorf("wrong formatting. expected=%q, got=%q", expected, edits[0].NewText)
+	}
+	if edits[0].Range.End != (Position{Line: 1, Character: 16}) {
+		t.Errorf("wrong edit range. got=%+v", edits[0].Range)
+	}
+}
+
+func itoa(n int) string {
+	b, _ := json.Marshal(n)
+	return string(b)
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+	"inter/lsp"
+	"os"
+)
+
+func lspCommand(args []string) int {
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "usage: inter lsp\n")
+		return 2
+	}
+
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
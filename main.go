@@ -7,7 +7,27 @@ import (
 	"os/user"
 )
 
+// commands are the subcommands of inter, each returning the exit code.
+var commands = map[string]func(args []string) int{
+	"dap":   dapCommand,
+	"debug": debugCommand,
+	"fmt":   fmtCommand,
+	"lsp":   lspCommand,
+	"run":   runCommand,
+	"test":  testCommand,
+	"vet":   vetCommand,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		cmd, ok := commands[os.Args[1]]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "inter: unknown command %q\n", os.Args[1])
+			os.Exit(2)
+		}
+		os.Exit(cmd(os.Args[2:]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
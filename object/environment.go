@@ -1,5 +1,7 @@
 package object
 
+import "sync"
+
 func NewEnclosedEnv(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
@@ -11,13 +13,20 @@ func NewEnvironment() *Environment {
 	return &Environment{store: s}
 }
 
+// Environment is safe for concurrent use. A common pattern is to evaluate a
+// prelude once and run every script in NewEnclosedEnv(prelude): the scripts
+// then share the prelude bindings while their own bindings stay separate.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	e.mu.RUnlock()
+
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -25,6 +34,44 @@ func (e *Environment) Get(name string) (Object, bool) {
 }
 
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
 	e.store[name] = val
+	e.mu.Unlock()
 	return val
 }
+
+// Snapshot returns a copy of all the bindings visible from e, including the
+// ones of the enclosing environments. Later changes to e or its outer
+// environments are not reflected in the copy, and the other way around.
+func (e *Environment) Snapshot() *Environment {
+	snapshot := NewEnvironment()
+	for env := e; env != nil; env = env.outer {
+		env.mu.RLock()
+		for name, val := range env.store {
+			if _, ok := snapshot.store[name]; !ok {
+				snapshot.store[name] = val
+			}
+		}
+		env.mu.RUnlock()
+	}
+
+	return snapshot
+}
+
+// Outer returns the enclosing environment, or nil.
+func (e *Environment) Outer() *Environment {
+	return e.outer
+}
+
+// Locals returns a copy of the bindings of e, without the ones of the
+// enclosing environments.
+func (e *Environment) Locals() map[string]Object {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	locals := make(map[string]Object, len(e.store))
+	for name, val := range e.store {
+		locals[name] = val
+	}
+	return locals
+}
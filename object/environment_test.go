@@ -0,0 +1,92 @@
+package object
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	outer.Set("b", &Integer{Value: 2})
+	inner := NewEnclosedEnv(outer)
+	inner.Set("b", &Integer{Value: 3})
+
+	snapshot := inner.Snapshot()
+	outer.Set("a", &Integer{Value: 10})
+	inner.Set("c", &Integer{Value: 4})
+	snapshot.Set("d", &Integer{Value: 5})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected string
+	}{
+		{snapshot, "a", "1"},
+		{snapshot, "b", "3"},
+		{snapshot, "c", ""},
+		{snapshot, "d", "5"},
+		{inner, "a", "10"},
+		{inner, "d", ""},
+	}
+
+	for _, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if tt.expected == "" {
+			if ok {
+				t.Errorf("%s should not be bound. got=%s", tt.name, obj.Inspect())
+			}
+			continue
+		}
+
+		if !ok || obj.Inspect() != tt.expected {
+			t.Errorf("wrong value for %s. expected=%s, got=%v", tt.name, tt.expected, obj)
+		}
+	}
+}
+
+func TestEnvironmentConcurrentAccess(t *testing.T) {
+	shared := NewEnvironment()
+	shared.Set("x", &Integer{Value: 1})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			local := NewEnclosedEnv(shared)
+			for j := 0; j < 100; j++ {
+				name := fmt.Sprintf("v%d_%d", i, j)
+				shared.Set(name, &Integer{Value: int64(j)})
+				local.Set(name, TRUE)
+				if _, ok := local.Get("x"); !ok {
+					t.Errorf("x not found")
+				}
+				shared.Snapshot()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestLocalsAndOuter(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("a", &Integer{Value: 1})
+	inner := NewEnclosedEnv(outer)
+	inner.Set("b", &Integer{Value: 2})
+
+	locals := inner.Locals()
+	if len(locals) != 1 || locals["b"].Inspect() != "2" {
+		t.Errorf("wrong locals. got=%v", locals)
+	}
+
+	locals["c"] = &Integer{Value: 3}
+	if _, ok := inner.Get("c"); ok {
+		t.Errorf("changing the locals should not change the environment")
+	}
+
+	if inner.Outer() != outer || outer.Outer() != nil {
+		t.Errorf("wrong outer environments")
+	}
+}
@@ -0,0 +1,514 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var (
+	objectType   = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// ToObject converts a Go value into its script representation. Structs are
+// converted into hashes keyed by field name, which can be overridden with an
+// `inter:"name"` tag (`inter:"-"` skips the field), and funcs are wrapped with
+// NewBuiltin. time.Time and time.Duration values become times and durations.
+func ToObject(v interface{}) (Object, error) {
+	if v == nil {
+		return NULL, nil
+	}
+
+	return toObject(reflect.ValueOf(v), map[visit]bool{})
+}
+
+// visit identifies a pointer, map or slice being converted, to detect the
+// values that contain themselves.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+// enter records v as being converted, and fails if it already is.
+func enter(v reflect.Value, seen map[visit]bool) (visit, error) {
+	key := visit{ptr: v.Pointer(), typ: v.Type()}
+	if seen[key] {
+		return key, fmt.Errorf("cycle detected at %s", v.Type())
+	}
+	seen[key] = true
+	return key, nil
+}
+
+func toObject(v reflect.Value, seen map[visit]bool) (Object, error) {
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		return &Time{Value: v.Interface().(time.Time)}, nil
+	case durationType:
+		return &Duration{Value: time.Duration(v.Int())}, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return nil, fmt.Errorf("integer %d overflows INTEGER", u)
+		}
+		return &Integer{Value: int64(u)}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Ptr {
+			key, err := enter(v, seen)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+		return toObject(v.Elem(), seen)
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NULL, nil
+		}
+		if v.Kind() == reflect.Slice && v.Len() > 0 {
+			key, err := enter(v, seen)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(seen, key)
+		}
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			el, err := toObject(v.Index(i), seen)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = el
+		}
+		return &Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		key, err := enter(v, seen)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(seen, key)
+		pairs := make(map[HashKey]HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key(), seen)
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := toObject(iter.Value(), seen)
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+
+	case reflect.Struct:
+		pairs := make(map[HashKey]HashPair)
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			value, err := toObject(v.Field(i), seen)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %v", t.Field(i).Name, err)
+			}
+			key := &String{Value: name}
+			pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+		}
+		return &Hash{Pairs: pairs}, nil
+
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return NewBuiltin(v.Interface())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to an object", v.Type())
+}
+
+// fieldName reports the hash key used for a struct field, and whether the
+// field is converted at all.
+func fieldName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+
+	tag := f.Tag.Get("inter")
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+	return f.Name, true
+}
+
+// FromObject stores the Go representation of obj in the value pointed to by
+// ptr. It is the inverse of ToObject.
+func FromObject(obj Object, ptr interface{}) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("FromObject expects a non-nil pointer, got %T", ptr)
+	}
+
+	return fromObject(obj, v.Elem())
+}
+
+func fromObject(obj Object, dst reflect.Value) error {
+	t := dst.Type()
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		if value := toInterface(obj); value != nil {
+			dst.Set(reflect.ValueOf(value))
+		} else {
+			dst.Set(reflect.Zero(t))
+		}
+		return nil
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		dst.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	switch t {
+	case timeType:
+		tm, ok := obj.(*Time)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		dst.Set(reflect.ValueOf(tm.Value))
+		return nil
+	case durationType:
+		d, ok := obj.(*Duration)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		dst.SetInt(int64(d.Value))
+		return nil
+	}
+
+	if obj == NULL {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			dst.Set(reflect.Zero(t))
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*Boolean)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		dst.SetBool(b.Value)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		if dst.OverflowInt(i.Value) {
+			return fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		dst.SetInt(i.Value)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, ok := obj.(*Integer)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		if i.Value < 0 || dst.OverflowUint(uint64(i.Value)) {
+			return fmt.Errorf("integer %d overflows %s", i.Value, t)
+		}
+		dst.SetUint(uint64(i.Value))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			dst.SetFloat(n.Value)
+		case *Integer:
+			dst.SetFloat(float64(n.Value))
+		default:
+			return mismatch(obj, t)
+		}
+		return nil
+
+	case reflect.String:
+		s, ok := obj.(*String)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		dst.SetString(s.Value)
+		return nil
+
+	case reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if err := fromObject(obj, elem.Elem()); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+
+	case reflect.Slice:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		slice := reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		for i, el := range arr.Elements {
+			if err := fromObject(el, slice.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %v", i, err)
+			}
+		}
+		dst.Set(slice)
+		return nil
+
+	case reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		if len(arr.Elements) != t.Len() {
+			return fmt.Errorf("cannot convert ARRAY of length %d to %s", len(arr.Elements), t)
+		}
+		for i, el := range arr.Elements {
+			if err := fromObject(el, dst.Index(i)); err != nil {
+				return fmt.Errorf("index %d: %v", i, err)
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		m := reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key := reflect.New(t.Key()).Elem()
+			if err := fromObject(pair.Key, key); err != nil {
+				return fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+			}
+			value := reflect.New(t.Elem()).Elem()
+			if err := fromObject(pair.Value, value); err != nil {
+				return fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+			}
+			m.SetMapIndex(key, value)
+		}
+		dst.Set(m)
+		return nil
+
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			return mismatch(obj, t)
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			key := &String{Value: name}
+			pair, ok := hash.Pairs[key.HashKey()]
+			if !ok {
+				continue
+			}
+			if err := fromObject(pair.Value, dst.Field(i)); err != nil {
+				return fmt.Errorf("field %s: %v", t.Field(i).Name, err)
+			}
+		}
+		return nil
+	}
+
+	return mismatch(obj, t)
+}
+
+// toInterface converts obj into the natural Go value used for interface{}
+// targets.
+func toInterface(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value
+	case *Float:
+		return obj.Value
+	case *Time:
+		return obj.Value
+	case *Duration:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *String:
+		return obj.Value
+	case *Null:
+		return nil
+	case *Array:
+		res := make([]interface{}, len(obj.Elements))
+		for i, el := range obj.Elements {
+			res[i] = toInterface(el)
+		}
+		return res
+	case *Hash:
+		allStrings := true
+		for _, pair := range obj.Pairs {
+			if pair.Key.Type() != STRING_OBJ {
+				allStrings = false
+				break
+			}
+		}
+
+		if allStrings {
+			res := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				res[pair.Key.(*String).Value] = toInterface(pair.Value)
+			}
+			return res
+		}
+
+		res := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			res[toInterface(pair.Key)] = toInterface(pair.Value)
+		}
+		return res
+	default:
+		return obj
+	}
+}
+
+func mismatch(obj Object, t reflect.Type) error {
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+// NewBuiltin wraps any Go func into a builtin that can be called from
+// scripts. Arguments are converted with FromObject and checked against the
+// parameter types, results are converted with ToObject. A trailing error
+// result is reported as an *Error when it is not nil, and so is a panic of
+// fn. Multiple results are returned as an array.
+func NewBuiltin(fn interface{}) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("NewBuiltin expects a func, got %T", fn)
+	}
+
+	t := v.Type()
+	numOut := t.NumOut()
+	returnsError := numOut > 0 && t.Out(numOut-1) == errorType
+	if returnsError {
+		numOut--
+	}
+
+	call := func(args ...Object) (res Object) {
+		in, errObj := convertArguments(t, args)
+		if errObj != nil {
+			return errObj
+		}
+
+		defer func() {
+			if r := recover(); r != nil {
+				res = &Error{Message: fmt.Sprintf("builtin panicked: %v", r), Kind: KindError}
+			}
+		}()
+		out := v.Call(in)
+		if returnsError {
+			if err, _ := out[len(out)-1].Interface().(error); err != nil {
+				return &Error{Message: err.Error(), Kind: KindError, Err: err}
+			}
+			out = out[:numOut]
+		}
+
+		results := make([]Object, len(out))
+		for i, res := range out {
+			obj, err := toObject(res, map[visit]bool{})
+			if err != nil {
+				return &Error{Message: err.Error(), Kind: KindType, Err: err}
+			}
+			results[i] = obj
+		}
+
+		switch len(results) {
+		case 0:
+			return NULL
+		case 1:
+			return results[0]
+		default:
+			return &Array{Elements: results}
+		}
+	}
+
+	return &Builtin{Fn: call}, nil
+}
+
+func convertArguments(t reflect.Type, args []Object) ([]reflect.Value, *Error) {
+	numIn := t.NumIn()
+	if t.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want at least %d", len(args), numIn-1), Kind: KindArgument}
+		}
+	} else if len(args) != numIn {
+		return nil, &Error{Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), numIn), Kind: KindArgument}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var pt reflect.Type
+		if t.IsVariadic() && i >= numIn-1 {
+			pt = t.In(numIn - 1).Elem()
+		} else {
+			pt = t.In(i)
+		}
+
+		val := reflect.New(pt).Elem()
+		if err := fromObject(arg, val); err != nil {
+			return nil, &Error{Message: fmt.Sprintf("argument %d: %v", i+1, err), Kind: KindType}
+		}
+		in[i] = val
+	}
+
+	return in, nil
+}
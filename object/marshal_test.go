@@ -0,0 +1,224 @@
+package object
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type address struct {
+	City string
+	Zip  int `inter:"zip"`
+}
+
+type person struct {
+	Name    string
+	Age     int
+	Tags    []string
+	Address *address
+	Secret  string `inter:"-"`
+	private int
+}
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "Null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{1.5, "1.5"},
+		{float32(2), "2.0"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+		{90 * time.Second, "1m30s"},
+		{1e21, "1e+21"},
+		{true, "true"},
+		{"hello", "hello"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{map[string]int{"a": 1, "b": 2}, "{a: 1, b: 2}"},
+		{(*address)(nil), "Null"},
+		{
+			person{Name: "Ann", Age: 30, Tags: []string{"x"}, Address: &address{City: "Oslo", Zip: 150}, Secret: "s"},
+			"{Address: {City: Oslo, zip: 150}, Age: 30, Name: Ann, Tags: [x]}",
+		},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) failed: %v", tt.input, err)
+			continue
+		}
+
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. expected=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	type node struct{ Next *node }
+	cyclic := &node{}
+	cyclic.Next = cyclic
+	selfMap := map[string]interface{}{}
+	selfMap["self"] = selfMap
+	selfSlice := []interface{}{nil}
+	selfSlice[0] = selfSlice
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{cyclic, "field Next: cycle detected at *object.node"},
+		{selfMap, "cycle detected at map[string]interface {}"},
+		{selfSlice, "cycle detected at []interface {}"},
+		{[]interface{}{[]interface{}{selfMap}}, "cycle detected at map[string]interface {}"},
+		{make(chan int), "cannot convert chan int to an object"},
+		{uint64(1 << 63), "integer 9223372036854775808 overflows INTEGER"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToObject(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+
+	// A value referenced twice without containing itself is not a cycle.
+	shared := []int{1}
+	if obj, err := ToObject([][]int{shared, shared}); err != nil || obj.Inspect() != "[[1], [1]]" {
+		t.Errorf("shared slice wrong. got=%v, %v", obj, err)
+	}
+}
+
+func TestFromObjectRoundTrip(t *testing.T) {
+	in := person{Name: "Bob", Age: 41, Tags: []string{"a", "b"}, Address: &address{City: "Rome", Zip: 100}}
+
+	obj, err := ToObject(in)
+	if err != nil {
+		t.Fatalf("ToObject failed: %v", err)
+	}
+
+	var out person
+	if err := FromObject(obj, &out); err != nil {
+		t.Fatalf("FromObject failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip mismatch. expected=%+v, got=%+v", in, out)
+	}
+}
+
+func TestFromObjectInterface(t *testing.T) {
+	obj := &Array{Elements: []Object{
+		&Integer{Value: 1},
+		&String{Value: "two"},
+		NULL,
+		&Hash{Pairs: map[HashKey]HashPair{
+			(&String{Value: "k"}).HashKey(): {Key: &String{Value: "k"}, Value: TRUE},
+		}},
+	}}
+
+	var out interface{}
+	if err := FromObject(obj, &out); err != nil {
+		t.Fatalf("FromObject failed: %v", err)
+	}
+
+	expected := []interface{}{int64(1), "two", nil, map[string]interface{}{"k": true}}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("wrong value. expected=%#v, got=%#v", expected, out)
+	}
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	var small int8
+	if err := FromObject(&Integer{Value: 300}, &small); err == nil || err.Error() != "integer 300 overflows int8" {
+		t.Errorf("wrong error for overflow. got=%v", err)
+	}
+
+	var s string
+	if err := FromObject(TRUE, &s); err == nil || err.Error() != "cannot convert BOOLEAN to string" {
+		t.Errorf("wrong error for mismatch. got=%v", err)
+	}
+
+	if err := FromObject(TRUE, s); err == nil {
+		t.Errorf("expected error for non-pointer target")
+	}
+}
+
+func TestNewBuiltin(t *testing.T) {
+	divide, err := NewBuiltin(func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	})
+	if err != nil {
+		t.Fatalf("NewBuiltin failed: %v", err)
+	}
+
+	sum, _ := NewBuiltin(func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	})
+	pair, _ := NewBuiltin(func(s string) (string, int) { return s, len(s) })
+	half, _ := NewBuiltin(func(f float64) float64 { return f / 2 })
+	later, _ := NewBuiltin(func(t time.Time, d time.Duration) time.Time { return t.Add(d) })
+	noop, _ := NewBuiltin(func() {})
+	index, _ := NewBuiltin(func(xs []int, i int) int { return xs[i] })
+
+	tests := []struct {
+		fn       *Builtin
+		args     []Object
+		expected string
+	}{
+		{divide, []Object{&Integer{Value: 7}, &Integer{Value: 2}}, "3"},
+		{divide, []Object{&Integer{Value: 7}, &Integer{Value: 0}}, "division by zero"},
+		{divide, []Object{&Integer{Value: 7}}, "wrong number of arguments. got=1, want=2"},
+		{divide, []Object{&Integer{Value: 7}, &String{Value: "x"}}, "argument 2: cannot convert STRING to int"},
+		{sum, []Object{}, "0"},
+		{sum, []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}}, "6"},
+		{pair, []Object{&String{Value: "abc"}}, "[abc, 3]"},
+		{noop, []Object{}, "Null"},
+		{half, []Object{&Float{Value: 3}}, "1.5"},
+		{half, []Object{&Integer{Value: 4}}, "2.0"},
+		{half, []Object{TRUE}, "argument 1: cannot convert BOOLEAN to float64"},
+		{later, []Object{&Time{Value: time.Unix(0, 0).UTC()}, &Duration{Value: time.Hour}}, "1970-01-01T01:00:00Z"},
+		{later, []Object{&Time{}, &Integer{Value: 1}}, "argument 2: cannot convert INTEGER to time.Duration"},
+		{index, []Object{&Array{}, &Integer{Value: 1}}, "builtin panicked: runtime error: index out of range [1] with length 0"},
+	}
+
+	for _, tt := range tests {
+		res := tt.fn.Fn(tt.args...)
+		if res.Inspect() != tt.expected {
+			t.Errorf("wrong result. expected=%q, got=%q", tt.expected, res.Inspect())
+		}
+	}
+
+	unconvertible, _ := NewBuiltin(func() chan int { return nil })
+	kinds := []struct {
+		fn       *Builtin
+		args     []Object
+		expected string
+	}{
+		{divide, []Object{&Integer{Value: 7}, &Integer{Value: 0}}, KindError},
+		{divide, []Object{&Integer{Value: 7}, &String{Value: "x"}}, KindType},
+		{divide, []Object{}, KindArgument},
+		{index, []Object{&Array{}, &Integer{Value: 1}}, KindError},
+		{unconvertible, []Object{}, KindType},
+	}
+	for _, tt := range kinds {
+		err, ok := tt.fn.Fn(tt.args...).(*Error)
+		if !ok || err.Kind != tt.expected {
+			t.Errorf("wrong error kind. expected=%q, got=%#v", tt.expected, err)
+		}
+	}
+
+	if _, err := NewBuiltin(42); err == nil {
+		t.Errorf("expected error for non-func value")
+	}
+}
@@ -1,19 +1,43 @@
 package object
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"hash/fnv"
 	"inter/ast"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	INTEGER_OBJ     = "INTEGER"
+	FLOAT_OBJ       = "FLOAT"
 	BOOLEAN_OBJ     = "BOOLEAN"
 	NULL_OBJ        = "NULL"
 	RETURNVALUE_OBJ = "RETURNVALUE"
 	ERROR_OBJ       = "ERROR"
+	ERRORVALUE_OBJ  = "ERROR_VALUE"
 	FUNCTION_OBJ    = "FUNCTION"
+	STRING_OBJ      = "STRING"
+	ARRAY_OBJ       = "ARRAY"
+	HASH_OBJ        = "HASH"
+	BUILTIN_OBJ     = "BUILTIN"
+	MODULE_OBJ      = "MODULE"
+	FILE_OBJ        = "FILE"
+	TIME_OBJ        = "TIME"
+	DURATION_OBJ    = "DURATION"
+	REGEXP_OBJ      = "REGEXP"
+)
+
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
 )
 
 type ObjectType string
@@ -30,6 +54,22 @@ type Integer struct {
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
+// Inspect formats f with the fewest digits that read back as the same
+// value, keeping a decimal point so that floats never look like integers.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
 type Boolean struct {
 	Value bool
 }
@@ -50,15 +90,163 @@ type ReturnValue struct {
 func (n *ReturnValue) Type() ObjectType { return RETURNVALUE_OBJ }
 func (n *ReturnValue) Inspect() string  { return n.Value.Inspect() }
 
+// Kinds of errors, as seen by scripts catching them.
+const (
+	KindError        = "Error" // the default kind
+	KindType         = "TypeError"
+	KindName         = "NameError"
+	KindArgument     = "ArgumentError"
+	KindZeroDivision = "ZeroDivisionError"
+	KindCapability   = "CapabilityError"
+	KindLimit        = "LimitError"
+	KindCancelled    = "CancelledError"
+	KindImport       = "ImportError"
+	KindIO           = "IOError"
+)
+
+// Error aborts the evaluation until a try expression catches it.
 type Error struct {
 	Message string
+	Kind    string       // one of the Kind constants or a script defined kind, KindError if empty
+	Err     error        // the underlying Go error, if any
+	Stack   []StackFrame // innermost first, set by the evaluator
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return e.Message }
 
+// KindName returns the kind of e, defaulting to KindError.
+func (e *Error) KindName() string {
+	if e.Kind == "" {
+		return KindError
+	}
+	return e.Kind
+}
+
+// ErrorValue is an error as an ordinary value: caught by a try expression
+// or created by a script. It only aborts the evaluation once thrown.
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Type() ObjectType { return ERRORVALUE_OBJ }
+func (ev *ErrorValue) Inspect() string {
+	return ev.Error.KindName() + ": " + ev.Error.Message
+}
+
+// StackFrame is the position a function was evaluating when an error
+// occurred: where the error occurred for the innermost frame, and the call
+// site of the next frame for the others.
+type StackFrame struct {
+	Function string // "<main>" for the program, "<anonymous>" for unnamed functions
+	File     string // the file of the code, empty if unknown
+	Line     int
+	Column   int
+	Elided   int // the number of calls below this one replaced by tail calls
+}
+
+// maxTraceback is the number of frames printed by Traceback, half from
+// each end of the stack.
+const maxTraceback = 20
+
+// Traceback returns the message followed by the stack, one frame per line.
+// Positions are prefixed with the file of their frame, or with file for
+// frames without one, if not empty. Deep stacks are shortened, and the
+// frames replaced by tail calls are counted below the frame replacing them.
+func (e *Error) Traceback(file string) string {
+	var out bytes.Buffer
+	out.WriteString(e.Message)
+
+	for i, f := range e.Stack {
+		if len(e.Stack) > maxTraceback && i == maxTraceback/2 {
+			fmt.Fprintf(&out, "\n  ... %d more frames", len(e.Stack)-maxTraceback)
+		}
+		if len(e.Stack) > maxTraceback && i >= maxTraceback/2 && i < len(e.Stack)-maxTraceback/2 {
+			continue
+		}
+
+		pos := fmt.Sprintf("%d:%d", f.Line, f.Column)
+		if f.File != "" {
+			pos = f.File + ":" + pos
+		} else if file != "" {
+			pos = file + ":" + pos
+		}
+		fmt.Fprintf(&out, "\n  at %s (%s)", f.Function, pos)
+		switch {
+		case f.Elided == 1:
+			out.WriteString("\n  ... 1 tail call elided")
+		case f.Elided > 1:
+			fmt.Fprintf(&out, "\n  ... %d tail calls elided", f.Elided)
+		}
+	}
+	return out.String()
+}
+
+// Module is an imported file. Its members are the top-level bindings of
+// the file, except the ones whose name starts with an underscore. A
+// module without a path is a namespace of builtins, such as strings.
+type Module struct {
+	Name    string
+	Path    string
+	Env     *Environment
+	Exports []string // in order of definition
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+func (m *Module) Inspect() string  { return "<module " + m.Name + ">" }
+
+// Builtin reports whether m is a namespace of builtins rather than a file.
+func (m *Module) Builtin() bool { return m.Path == "" }
+
+// Member returns the exported binding name of m.
+func (m *Module) Member(name string) (Object, bool) {
+	for _, export := range m.Exports {
+		if export == name {
+			return m.Env.Get(name)
+		}
+	}
+	return nil, false
+}
+
+// File is a file opened by a script to read it line by line.
+type File struct {
+	Name    string
+	Scanner *bufio.Scanner
+	Closer  io.Closer // nil once the file is closed
+}
+
+func (f *File) Type() ObjectType { return FILE_OBJ }
+func (f *File) Inspect() string  { return "<file " + f.Name + ">" }
+
+// Time is an instant, printed in RFC 3339 format.
+type Time struct {
+	Value time.Time
+}
+
+func (t *Time) Type() ObjectType { return TIME_OBJ }
+func (t *Time) Inspect() string  { return t.Value.Format(time.RFC3339Nano) }
+
+// Duration is the time elapsed between two instants, printed like "1h30m0s".
+type Duration struct {
+	Value time.Duration
+}
+
+func (d *Duration) Type() ObjectType { return DURATION_OBJ }
+func (d *Duration) Inspect() string  { return d.Value.String() }
+
+// Regexp is a compiled regular expression, reused across matches.
+type Regexp struct {
+	Value *regexp.Regexp
+}
+
+func (r *Regexp) Type() ObjectType { return REGEXP_OBJ }
+func (r *Regexp) Inspect() string  { return "<regexp " + r.Value.String() + ">" }
+
 type Function struct {
+	Name       string // the name of the let statement defining it, if any
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // nil, or the default value of each parameter
+	Rest       *ast.Identifier  // the ...rest parameter, if any
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -67,17 +255,103 @@ func (fn *Function) Type() ObjectType { return FUNCTION_OBJ }
 func (f *Function) Inspect() string {
 	var out bytes.Buffer
 
-	params := []string{}
-	for _, p := range f.Parameters {
-		params = append(params, p.String())
-	}
-
 	out.WriteString("fn")
 	out.WriteString("(")
-	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(ast.ParameterList(f.Parameters, f.Defaults, f.Rest))
 	out.WriteString(") {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
+
+// Signature returns the name and parameters of f, as in "add(a, b = 1)",
+// or "fn(...)" for anonymous functions.
+func (f *Function) Signature() string {
+	name := f.Name
+	if name == "" {
+		name = "fn"
+	}
+	return name + "(" + ast.ParameterList(f.Parameters, f.Defaults, f.Rest) + ")"
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, el := range a.Elements {
+		elements = append(elements, el.Inspect())
+	}
+
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable is implemented by the objects that can be used as hash keys.
+type Hashable interface {
+	HashKey() HashKey
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	// Map iteration order is random, sort to keep the output stable.
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Fn BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin function" }
@@ -17,6 +17,7 @@ const (
 	PRODUCT     // *
 	PREFIX      // -X or !X
 	CALL        // myFunction(X)
+	INDEX       // array[index]
 )
 
 var precedences = map[token.TokenType]int{
@@ -29,6 +30,8 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type (
@@ -41,7 +44,7 @@ type Parser struct {
 
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	errors         []Error
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -49,13 +52,14 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:              l,
-		errors:         []string{},
+		errors:         []Error{},
 		prefixParseFns: make(map[token.TokenType]prefixParseFn),
 		infixParseFns:  make(map[token.TokenType]infixParseFn),
 	}
 
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerIdentifier)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
@@ -63,6 +67,10 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
@@ -73,16 +81,44 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
 
 	p.nextToken()
 	p.nextToken()
 	return p
 }
 
+// Error is a syntax error at the position of the offending token.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Errors returns the messages of the syntax errors found so far.
 func (p *Parser) Errors() []string {
+	msgs := []string{}
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Message)
+	}
+	return msgs
+}
+
+// ErrorList returns the syntax errors found so far, with their positions.
+func (p *Parser) ErrorList() []Error {
 	return p.errors
 }
 
+func (p *Parser) addError(tok token.Token, format string, args ...interface{}) {
+	err := Error{Line: tok.Line, Column: tok.Column, Message: fmt.Sprintf(format, args...)}
+	p.errors = append(p.errors, err)
+}
+
 func (p *Parser) registerPrefix(tType token.TokenType, fn prefixParseFn) {
 	p.prefixParseFns[tType] = fn
 }
@@ -99,9 +135,20 @@ func (p *Parser) nextToken() {
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		// Avoid returning a nil *ast.LetStatement as a non-nil Statement.
+		if st := p.parseLetStatement(); st != nil {
+			return st
+		}
+		return nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		if st := p.parseImportStatement(); st != nil {
+			return st
+		}
+		return nil
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -122,6 +169,58 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return st
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	st := &ast.ThrowStatement{Token: p.curToken}
+
+	p.nextToken()
+	st.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return st
+}
+
+// parseImportStatement parses import "path" and import name from "path".
+// from is only a keyword in this position.
+func (p *Parser) parseImportStatement() *ast.ImportStatement {
+	st := &ast.ImportStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.IDENT) {
+		p.nextToken()
+		st.Alias = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "from" {
+			p.addError(p.peekToken, "expected from after import name, got=%q", p.peekToken.Literal)
+			return nil
+		}
+		p.nextToken()
+	}
+
+	if !p.peekTokenIsThenAdvance(token.STRING) {
+		return nil
+	}
+	st.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+
+	if st.Alias == nil && !isIdentifier(st.Name()) {
+		p.addError(p.curToken, "cannot import %q without a name, use import name from %q", st.Path.Value, st.Path.Value)
+		return nil
+	}
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return st
+}
+
+// isIdentifier reports whether name would be lexed as an identifier.
+func isIdentifier(name string) bool {
+	tok := lexer.New(name).NextToken()
+	return tok.Type == token.IDENT && tok.Literal == name
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	st := &ast.LetStatement{Token: p.curToken}
 
@@ -139,6 +238,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	// To skip the semicolon
 	st.Value = p.parseExpression(LOWEST)
+	if fn, ok := st.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = st.Name.Value
+	}
 
 	// To skip the semicolon
 	if p.peekTokenIs(token.SEMICOLON) {
@@ -195,8 +297,7 @@ func (p *Parser) ParseProgram() *ast.Program {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	err := fmt.Sprintf("Expected=%q, got=%q", t, p.peekToken.Type)
-	p.errors = append(p.errors, err)
+	p.addError(p.peekToken, "Expected=%q, got=%q", t, p.peekToken.Type)
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
@@ -232,27 +333,94 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return ifExp
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	ids := []*ast.Identifier{}
-	if p.peekTokenIs(token.RPAREN) {
+func (p *Parser) parseTryExpression() ast.Expression {
+	exp := &ast.TryExpression{Token: p.curToken}
+
+	if !p.peekTokenIsThenAdvance(token.LBRACE) {
+		return nil
+	}
+	exp.Body = p.parseBlockStatements()
+
+	if p.peekTokenIs(token.CATCH) {
 		p.nextToken()
-		return ids
+		if !p.peekTokenIsThenAdvance(token.LPAREN) || !p.peekTokenIsThenAdvance(token.IDENT) {
+			return nil
+		}
+		exp.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.peekTokenIsThenAdvance(token.RPAREN) || !p.peekTokenIsThenAdvance(token.LBRACE) {
+			return nil
+		}
+		exp.CatchBody = p.parseBlockStatements()
 	}
 
-	p.nextToken()
-	id := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	ids = append(ids, id)
-	for p.peekTokenIs(token.COMMA) {
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.peekTokenIsThenAdvance(token.LBRACE) {
+			return nil
+		}
+		exp.FinallyBody = p.parseBlockStatements()
+	}
+
+	if exp.CatchBody == nil && exp.FinallyBody == nil {
+		p.addError(p.peekToken, "expected catch or finally after try block")
+		return nil
+	}
+
+	return exp
+}
+
+// parseFunctionParameters parses the parameters of fl: names, optionally
+// followed by a default value, and a final ...rest parameter.
+func (p *Parser) parseFunctionParameters(fl *ast.FunctionLiteral) bool {
+	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
+		return true
+	}
+
+	hasDefaults := false
+	for {
 		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			if !p.peekTokenIsThenAdvance(token.IDENT) {
+				return false
+			}
+			fl.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.RPAREN) {
+				p.addError(p.peekToken, "rest parameter ...%s must be the last parameter", fl.Rest.Value)
+				return false
+			}
+			break
+		}
+
+		if !p.curTokenIs(token.IDENT) {
+			p.addError(p.curToken, "expected parameter name, got=%q", p.curToken.Literal)
+			return false
+		}
 		id := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		ids = append(ids, id)
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			def = p.parseExpression(LOWEST)
+			hasDefaults = true
+		} else if hasDefaults {
+			p.addError(id.Token, "parameter %s without a default follows parameters with defaults", id.Value)
+			return false
+		}
+		fl.FunctionParameters = append(fl.FunctionParameters, id)
+		fl.Defaults = append(fl.Defaults, def)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
-	if !p.peekTokenIsThenAdvance(token.RPAREN) {
-		return nil
+	if !hasDefaults {
+		fl.Defaults = nil
 	}
-	return ids
+	return p.peekTokenIsThenAdvance(token.RPAREN)
 }
 
 func (p *Parser) parseFunctionLiteral() ast.Expression {
@@ -263,13 +431,16 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
-	res.FunctionParameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(res) {
+		return nil
+	}
 
 	if !p.peekTokenIsThenAdvance(token.LBRACE) {
 		return nil
 	}
 
 	res.FunctionBody = p.parseBlockStatements()
+	ast.MarkTailCalls(res.FunctionBody)
 
 	return res
 }
@@ -280,11 +451,13 @@ func (p *Parser) parseBlockStatements() *ast.BlockStatement {
 	p.nextToken()
 
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		curSt := p.parseStatement()
-		st.Statements = append(st.Statements, curSt)
+		if curSt := p.parseStatement(); curSt != nil {
+			st.Statements = append(st.Statements, curSt)
+		}
 		p.nextToken()
 	}
 
+	st.EndToken = p.curToken
 	return st
 }
 
@@ -322,8 +495,7 @@ func (p *Parser) parseIntegerIdentifier() ast.Expression {
 
 	val, err := strconv.ParseInt(p.curToken.Literal, 10, 64)
 	if err != nil {
-		msg := fmt.Sprintf("Cannot parse %s as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken, "Cannot parse %s as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -331,9 +503,18 @@ func (p *Parser) parseIntegerIdentifier() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	val, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(p.curToken, "Cannot parse %s as float", p.curToken.Literal)
+		return nil
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: val}
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -355,7 +536,7 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 
 	exp := p.parseExpression(LOWEST)
 	if !p.peekTokenIs(token.RPAREN) {
-		p.errors = append(p.errors, "Unmatched left ( found")
+		p.addError(p.peekToken, "Unmatched left ( found")
 		return nil
 	}
 	p.nextToken()
@@ -368,6 +549,8 @@ func (p *Parser) parseCallExpression(fn ast.Expression) ast.Expression {
 	return exp
 }
 
+// parseCallArguments parses the arguments of a call: expressions, spread
+// arrays ...xs and named arguments name: value, which come last.
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 	if p.peekTokenIs(token.RPAREN) {
@@ -375,21 +558,135 @@ func (p *Parser) parseCallArguments() []ast.Expression {
 		return args
 	}
 
+	named := map[string]bool{}
+	for {
+		p.nextToken()
+		start := p.curToken
+		arg := p.parseCallArgument()
+		if arg, ok := arg.(*ast.NamedArgument); ok {
+			if named[arg.Name.Value] {
+				p.addError(arg.Name.Token, "argument %s given twice", arg.Name.Value)
+			}
+			named[arg.Name.Value] = true
+		} else if len(named) > 0 {
+			p.addError(start, "positional argument after named argument")
+		}
+		args = append(args, arg)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.peekTokenIsThenAdvance(token.RPAREN) {
+		return nil
+	}
+	return args
+}
+
+func (p *Parser) parseCallArgument() ast.Expression {
+	switch {
+	case p.curTokenIs(token.ELLIPSIS):
+		spread := &ast.SpreadExpression{Token: p.curToken}
+		p.nextToken()
+		spread.Value = p.parseExpression(LOWEST)
+		return spread
+	case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.nextToken()
+		arg := &ast.NamedArgument{Token: p.curToken, Name: name}
+		p.nextToken()
+		arg.Value = p.parseExpression(LOWEST)
+		return arg
+	default:
+		return p.parseExpression(LOWEST)
+	}
+}
+
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
 	p.nextToken()
-	arg := p.parseExpression(LOWEST)
-	args = append(args, arg)
+	list = append(list, p.parseExpression(LOWEST))
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		arg := p.parseExpression(LOWEST)
-		args = append(args, arg)
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.peekTokenIsThenAdvance(token.RPAREN) {
+	if !p.peekTokenIsThenAdvance(end) {
 		return nil
 	}
 
-	return args
+	return list
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+
+	if !p.peekTokenIsThenAdvance(token.RBRACKET) {
+		return nil
+	}
+
+	return exp
+}
+
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+
+	if !p.peekTokenIsThenAdvance(token.IDENT) {
+		return nil
+	}
+	exp.Property = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	return exp
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	hash.Keys = []ast.Expression{}
+	hash.Pairs = make(map[ast.Expression]ast.Expression)
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+
+		if !p.peekTokenIsThenAdvance(token.COLON) {
+			return nil
+		}
+
+		p.nextToken()
+		hash.Keys = append(hash.Keys, key)
+		hash.Pairs[key] = p.parseExpression(LOWEST)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.peekTokenIsThenAdvance(token.COMMA) {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIsThenAdvance(token.RBRACE) {
+		return nil
+	}
+
+	return hash
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
@@ -407,6 +704,17 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return exp
 }
 
+// Precedence reports how tightly the infix operator t binds, LOWEST if t is
+// not an infix operator.
+func Precedence(t token.TokenType) int {
+	pre, ok := precedences[t]
+	if !ok {
+		return LOWEST
+	}
+
+	return pre
+}
+
 func (p *Parser) peekPrecedence() int {
 	pre, ok := precedences[p.peekToken.Type]
 	if !ok {
@@ -158,6 +158,23 @@ func TestIntegerLiteral(t *testing.T) {
 	}
 }
 
+func TestFloatLiteral(t *testing.T) {
+	program := parse("2.5;", 1, t)
+
+	st, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected ExpressionStatement, got %T", program.Statements[0])
+	}
+
+	f, ok := st.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("Expected FloatLiteral, got %T", st.Expression)
+	}
+	if f.Value != 2.5 {
+		t.Fatalf("Expected 2.5, got %g", f.Value)
+	}
+}
+
 func TestPrefixExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -395,6 +412,82 @@ func TestFuncLiteral(t *testing.T) {
 	}
 }
 
+func TestFuncLiteralParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, b = 2, ...rest) { a }", "fn(a, b = 2, ...rest) {a}"},
+		{"fn(a = 1 + 2, b = a) { b }", "fn(a = (1 + 2), b = a) {b}"},
+		{"fn(...xs) { xs }", "fn(...xs) {xs}"},
+		{"f(...xs, b: 3)", "f(...xs, b: 3)"},
+		{"f(1, ...[2, 3], c: 4 * 5, d: g(x: 1))", "f(1, ...[2, 3], c: (4 * 5), d: g(x: 1))"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input, 1, t)
+		if program.String() != tt.expected {
+			t.Errorf("wrong program for %q. expected=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestFuncLiteralParameterErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...xs, a) { a }", "1:9: rest parameter ...xs must be the last parameter"},
+		{"fn(a = 1, b) { a }", "1:11: parameter b without a default follows parameters with defaults"},
+		{"fn(1) { 1 }", `1:4: expected parameter name, got="1"`},
+		{"f(a: 1, 2)", "1:9: positional argument after named argument"},
+		{"f(a: 1, ...xs)", "1:9: positional argument after named argument"},
+		{"f(a: 1, a: 2)", "1:9: argument a given twice"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.ErrorList()
+		if len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the functions called in tail position
+	}{
+		{"fn() { a(); b() }", "[b]"},
+		{"fn() { a(b()) }", "[a]"},
+		{"fn() { 1 + a() }", "[]"},
+		{"fn() { if (x) { a() } else { b(); c() } }", "[a c]"},
+		{"fn() { if (x) { return a(); } b(); let y = c(); return d(); 1 }", "[a d]"},
+		{"fn() { if (x) { a() }; b }", "[]"},
+		{"fn() { try { a() } catch (e) { return b(); } }", "[]"},
+		{"fn() { fn() { a() }; b() }", "[a b]"},
+		{"fn() { a(fn() { b() }) }()", "[a b]"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input, 1, t)
+
+		tail := []string{}
+		ast.Inspect(program, func(n ast.Node) bool {
+			if call, ok := n.(*ast.CallExpression); ok && call.Tail {
+				tail = append(tail, call.Function.String())
+			}
+			return true
+		})
+		if fmt.Sprint(tail) != tt.expected {
+			t.Errorf("wrong tail calls for %q. expected=%s, got=%v", tt.input, tt.expected, tail)
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -472,6 +565,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"a * [1, 2, 3, 4][b * c] * d",
+			"((a * ([1, 2, 3, 4][(b * c)])) * d)",
+		},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{
+			"-a.b.c(d) + e.f[0]",
+			"((-((a.b).c)(d)) + ((e.f)[0]))",
+		},
 	}
 
 	for _, tt := range tests {
@@ -545,3 +650,223 @@ func testInfixExpression(t *testing.T, exp ast.Expression, left interface{}, ope
 	}
 	return true
 }
+
+func TestStringLiteralExpression(t *testing.T) {
+	program := parse(`"hello world";`, 1, t)
+	st := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := st.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", st.Expression)
+	}
+
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestArrayLiteral(t *testing.T) {
+	program := parse("[1, 2 * 2, 3 + 3]", 1, t)
+	st := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := st.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", st.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestIndexExpression(t *testing.T) {
+	program := parse("myArray[1 + 1]", 1, t)
+	st := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := st.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", st.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestHashLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]int64
+	}{
+		{`{}`, map[string]int64{}},
+		{`{"one": 1, "two": 2, "three": 3}`, map[string]int64{"one": 1, "two": 2, "three": 3}},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input, 1, t)
+		st := program.Statements[0].(*ast.ExpressionStatement)
+		hash, ok := st.Expression.(*ast.HashLiteral)
+		if !ok {
+			t.Fatalf("exp is not ast.HashLiteral. got=%T", st.Expression)
+		}
+
+		if len(hash.Pairs) != len(tt.expected) || len(hash.Keys) != len(tt.expected) {
+			t.Fatalf("hash has wrong number of pairs. got=%d", len(hash.Pairs))
+		}
+
+		for _, key := range hash.Keys {
+			literal, ok := key.(*ast.StringLiteral)
+			if !ok {
+				t.Errorf("key is not ast.StringLiteral. got=%T", key)
+				continue
+			}
+
+			testIntegerLiteral(t, hash.Pairs[key], tt.expected[literal.Value])
+		}
+	}
+}
+
+func TestMemberExpression(t *testing.T) {
+	program := parse("err.message", 1, t)
+	st := program.Statements[0].(*ast.ExpressionStatement)
+	member, ok := st.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", st.Expression)
+	}
+
+	testIdentifier(t, member.Left, "err")
+	testIdentifier(t, member.Property, "message")
+}
+
+func TestThrowStatement(t *testing.T) {
+	program := parse(`throw error("bad");`, 1, t)
+	st, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("st not *ast.ThrowStatement. got=%T", program.Statements[0])
+	}
+
+	if _, ok := st.Value.(*ast.CallExpression); !ok {
+		t.Errorf("throw value not *ast.CallExpression. got=%T", st.Value)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input      string
+		catchParam string
+		hasFinally bool
+	}{
+		{"try { x } catch (e) { y }", "e", false},
+		{"try { x } finally { y }", "", true},
+		{"try { x } catch (err) { y } finally { z }", "err", true},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input, 1, t)
+		st := program.Statements[0].(*ast.ExpressionStatement)
+		try, ok := st.Expression.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("exp not *ast.TryExpression. got=%T", st.Expression)
+		}
+
+		if len(try.Body.Statements) != 1 {
+			t.Errorf("wrong try body. got=%s", try.Body.String())
+		}
+
+		if tt.catchParam == "" {
+			if try.CatchBody != nil {
+				t.Errorf("unexpected catch block for %q", tt.input)
+			}
+		} else {
+			testIdentifier(t, try.CatchParam, tt.catchParam)
+			if try.CatchBody == nil || try.CatchBody.String() != "y" {
+				t.Errorf("wrong catch block for %q", tt.input)
+			}
+		}
+
+		if (try.FinallyBody != nil) != tt.hasFinally {
+			t.Errorf("wrong finally block for %q", tt.input)
+		}
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { x }", "1:10: expected catch or finally after try block"},
+		{"try { x } catch { y }", `1:17: Expected="(", got="{"`},
+		{"try { x } catch (1) { y }", `1:18: Expected="IDENT", got="INT"`},
+		{"a.1", `1:3: Expected="IDENT", got="INT"`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.ErrorList()
+		if len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", tt.input, tt.expected, errs)
+		}
+	}
+}
+
+func TestImportStatement(t *testing.T) {
+	tests := []struct {
+		input string
+		alias string
+		path  string
+		name  string
+	}{
+		{`import "lib/strings";`, "", "lib/strings", "strings"},
+		{`import "./util.mk"`, "", "./util.mk", "util"},
+		{`import s from "../my-strings";`, "s", "../my-strings", "s"},
+	}
+
+	for _, tt := range tests {
+		program := parse(tt.input, 1, t)
+		st, ok := program.Statements[0].(*ast.ImportStatement)
+		if !ok {
+			t.Fatalf("st not *ast.ImportStatement. got=%T", program.Statements[0])
+		}
+
+		if tt.alias == "" && st.Alias != nil {
+			t.Errorf("unexpected alias %s", st.Alias.Value)
+		}
+		if tt.alias != "" {
+			testIdentifier(t, st.Alias, tt.alias)
+		}
+		if st.Path.Value != tt.path {
+			t.Errorf("wrong path. expected=%q, got=%q", tt.path, st.Path.Value)
+		}
+		if st.Name() != tt.name {
+			t.Errorf("wrong name. expected=%q, got=%q", tt.name, st.Name())
+		}
+	}
+}
+
+func TestImportStatementErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`import "my-strings";`, `1:8: cannot import "my-strings" without a name, use import name from "my-strings"`},
+		{`import s "x";`, `1:10: expected from after import name, got="x"`},
+		{`import s from x;`, `1:15: Expected="STRING", got="IDENT"`},
+		{`import;`, `1:7: Expected="STRING", got=";"`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errs := p.ErrorList()
+		if len(errs) == 0 || errs[0].Error() != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%v", tt.input, tt.expected, errs)
+		}
+	}
+}
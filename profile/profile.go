@@ -0,0 +1,257 @@
+// Package profile collects CPU profiles of scripts and writes them in the
+// pprof format, so that go tool pprof shows script functions and lines.
+package profile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"inter/object"
+	"io"
+	"strings"
+	"time"
+)
+
+// DefaultPeriod is the sampling period used by inter run --cpuprofile, the
+// same as the Go runtime profiler.
+const DefaultPeriod = 10 * time.Millisecond
+
+// Profiler aggregates the stacks reported by a profiled interpreter. It
+// implements evaluator.Sampler.
+type Profiler struct {
+	file   string
+	period time.Duration
+	start  time.Time
+
+	samples []*sample
+	byStack map[string]*sample
+}
+
+type sample struct {
+	stack []object.StackFrame
+	count int64
+}
+
+// New returns a profiler for the script in file, sampled every period.
+// file is the source of the frames that do not name theirs.
+func New(file string, period time.Duration) *Profiler {
+	return &Profiler{
+		file:    file,
+		period:  period,
+		start:   time.Now(),
+		byStack: make(map[string]*sample),
+	}
+}
+
+// Sample records n samples of stack, innermost frame first.
+func (p *Profiler) Sample(stack []object.StackFrame, n int) {
+	key := stackKey(stack)
+	s, ok := p.byStack[key]
+	if !ok {
+		s = &sample{stack: stack}
+		p.byStack[key] = s
+		p.samples = append(p.samples, s)
+	}
+	s.count += int64(n)
+}
+
+// Samples returns the number of samples recorded so far.
+func (p *Profiler) Samples() int64 {
+	var total int64
+	for _, s := range p.samples {
+		total += s.count
+	}
+	return total
+}
+
+func stackKey(stack []object.StackFrame) string {
+	var b strings.Builder
+	for _, f := range stack {
+		fmt.Fprintf(&b, "%s:%s:%d\n", f.File, f.Function, f.Line)
+	}
+	return b.String()
+}
+
+// Write writes the profile to w as a gzipped pprof protocol buffer. Every
+// line of a script function becomes a location, so pprof can report both
+// per function and per line.
+func (p *Profiler) Write(w io.Writer) error {
+	e := &encoder{strings: map[string]int64{"": 0}, stringTable: []string{""}}
+	samples := e.ids(p)
+
+	var out buffer
+	out.message(1, e.valueType("samples", "count"))
+	out.message(1, e.valueType("cpu", "nanoseconds"))
+	for _, s := range samples {
+		out.message(2, s)
+	}
+	out.raw(e.locations.bytes)
+	out.raw(e.functions.bytes)
+	for _, s := range e.stringTable {
+		out.bytesField(6, []byte(s))
+	}
+	out.int(9, p.start.UnixNano())
+	out.int(10, int64(time.Since(p.start)))
+	out.message(11, e.valueType("cpu", "nanoseconds"))
+	out.int(12, int64(p.period))
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(out.bytes); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// encoder assigns the ids of the functions, locations and strings of a
+// profile, encoding each of them once.
+type encoder struct {
+	strings     map[string]int64
+	stringTable []string
+
+	functionIDs map[[2]string]uint64 // by file and name
+	functions   buffer
+
+	locationIDs map[object.StackFrame]uint64
+	locations   buffer
+}
+
+// ids encodes the locations and functions used by the samples of p, and
+// returns the encoded samples.
+func (e *encoder) ids(p *Profiler) []buffer {
+	e.functionIDs = make(map[[2]string]uint64)
+	e.locationIDs = make(map[object.StackFrame]uint64)
+
+	samples := []buffer{}
+	for _, s := range p.samples {
+		var locations []uint64
+		for _, f := range s.stack {
+			locations = append(locations, e.location(p.file, f))
+		}
+
+		var b buffer
+		b.packed(1, locations)
+		b.packed(2, []uint64{uint64(s.count), uint64(s.count * int64(p.period))})
+		samples = append(samples, b)
+	}
+	return samples
+}
+
+func (e *encoder) location(file string, f object.StackFrame) uint64 {
+	if f.File != "" {
+		file = f.File
+	}
+
+	// Columns are dropped: pprof aggregates by line.
+	key := object.StackFrame{Function: f.Function, File: file, Line: f.Line}
+	if id, ok := e.locationIDs[key]; ok {
+		return id
+	}
+
+	id := uint64(len(e.locationIDs) + 1)
+	e.locationIDs[key] = id
+
+	var line buffer
+	line.uint(1, e.function(file, f.Function))
+	line.int(2, int64(f.Line))
+
+	var loc buffer
+	loc.uint(1, id)
+	loc.message(4, line)
+	e.locations.message(4, loc)
+	return id
+}
+
+func (e *encoder) function(file, name string) uint64 {
+	key := [2]string{file, name}
+	if id, ok := e.functionIDs[key]; ok {
+		return id
+	}
+
+	id := uint64(len(e.functionIDs) + 1)
+	e.functionIDs[key] = id
+
+	// pprof drops anything in angle brackets as C++ template arguments,
+	// which would leave <main> and <anonymous> without a name.
+	display := strings.Trim(name, "<>")
+
+	var fn buffer
+	fn.uint(1, id)
+	fn.int(2, e.string(display))
+	fn.int(3, e.string(name))
+	fn.int(4, e.string(file))
+	e.functions.message(5, fn)
+	return id
+}
+
+func (e *encoder) valueType(typ, unit string) buffer {
+	var b buffer
+	b.int(1, e.string(typ))
+	b.int(2, e.string(unit))
+	return b
+}
+
+func (e *encoder) string(s string) int64 {
+	if i, ok := e.strings[s]; ok {
+		return i
+	}
+	i := int64(len(e.stringTable))
+	e.strings[s] = i
+	e.stringTable = append(e.stringTable, s)
+	return i
+}
+
+// buffer encodes the fields of a protocol buffer message.
+type buffer struct {
+	bytes []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *buffer) varint(v uint64) {
+	for v >= 0x80 {
+		b.bytes = append(b.bytes, byte(v)|0x80)
+		v >>= 7
+	}
+	b.bytes = append(b.bytes, byte(v))
+}
+
+func (b *buffer) key(field int, wire int) {
+	b.varint(uint64(field)<<3 | uint64(wire))
+}
+
+// uint and int omit zero values, as proto3 does.
+func (b *buffer) uint(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	b.key(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *buffer) int(field int, v int64) {
+	b.uint(field, uint64(v))
+}
+
+func (b *buffer) bytesField(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	b.bytes = append(b.bytes, v...)
+}
+
+func (b *buffer) message(field int, m buffer) {
+	b.bytesField(field, m.bytes)
+}
+
+func (b *buffer) packed(field int, vs []uint64) {
+	var p buffer
+	for _, v := range vs {
+		p.varint(v)
+	}
+	b.bytesField(field, p.bytes)
+}
+
+func (b *buffer) raw(bytes []byte) {
+	b.bytes = append(b.bytes, bytes...)
+}
@@ -0,0 +1,147 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"inter/object"
+	"io"
+	"testing"
+	"time"
+)
+
+// field is a decoded protocol buffer field: a varint or a byte string.
+type field struct {
+	num   int
+	value uint64
+	bytes []byte
+}
+
+// varint reads a varint from the start of *b.
+func varint(t *testing.T, b *[]byte) uint64 {
+	t.Helper()
+
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		if len(*b) == 0 {
+			t.Fatalf("truncated varint")
+		}
+		c := (*b)[0]
+		*b = (*b)[1:]
+		v |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return v
+		}
+	}
+}
+
+func decode(t *testing.T, b []byte) []field {
+	t.Helper()
+
+	var fields []field
+	for len(b) > 0 {
+		key := varint(t, &b)
+		f := field{num: int(key >> 3)}
+		switch key & 7 {
+		case wireVarint:
+			f.value = varint(t, &b)
+		case wireBytes:
+			n := varint(t, &b)
+			f.bytes, b = b[:n], b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func packed(t *testing.T, b []byte) []uint64 {
+	var vs []uint64
+	for len(b) > 0 {
+		vs = append(vs, varint(t, &b))
+	}
+	return vs
+}
+
+func TestSample(t *testing.T) {
+	p := New("main.mk", time.Millisecond)
+	inner := []object.StackFrame{{Function: "f", Line: 2, Column: 3}, {Function: "<main>", Line: 5, Column: 1}}
+	p.Sample(inner, 1)
+	p.Sample([]object.StackFrame{{Function: "<main>", Line: 6, Column: 1}}, 2)
+	p.Sample(inner, 3)
+
+	if p.Samples() != 6 {
+		t.Errorf("wrong number of samples. got=%d", p.Samples())
+	}
+	if len(p.samples) != 2 {
+		t.Errorf("identical stacks were not merged. got=%d stacks", len(p.samples))
+	}
+}
+
+func TestWrite(t *testing.T) {
+	p := New("main.mk", time.Millisecond)
+	p.Sample([]object.StackFrame{{Function: "f", Line: 2}, {Function: "<main>", Line: 5}}, 3)
+	p.Sample([]object.StackFrame{{Function: "<main>", Line: 5}}, 1)
+
+	var out bytes.Buffer
+	if err := p.Write(&out); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+
+	zr, err := gzip.NewReader(&out)
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %s", err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts := map[int]int{}
+	var strs []string
+	var values [][]uint64
+	for _, f := range decode(t, raw) {
+		counts[f.num]++
+		switch f.num {
+		case 2:
+			for _, sf := range decode(t, f.bytes) {
+				if sf.num == 2 {
+					values = append(values, packed(t, sf.bytes))
+				}
+			}
+		case 6:
+			strs = append(strs, string(f.bytes))
+		case 12:
+			if f.value != uint64(time.Millisecond) {
+				t.Errorf("wrong period. got=%d", f.value)
+			}
+		}
+	}
+
+	expected := map[int]int{1: 2, 2: 2, 4: 2, 5: 2, 11: 1, 12: 1}
+	for num, n := range expected {
+		if counts[num] != n {
+			t.Errorf("wrong number of fields %d. expected=%d, got=%d", num, n, counts[num])
+		}
+	}
+
+	expectedStrings := []string{"", "f", "main.mk", "main", "<main>", "samples", "count", "cpu", "nanoseconds"}
+	if len(strs) != len(expectedStrings) {
+		t.Fatalf("wrong string table. got=%q", strs)
+	}
+	for i, s := range expectedStrings {
+		if strs[i] != s {
+			t.Errorf("wrong string %d. expected=%q, got=%q", i, s, strs[i])
+		}
+	}
+
+	expectedValues := [][]uint64{{3, uint64(3 * time.Millisecond)}, {1, uint64(time.Millisecond)}}
+	if len(values) != 2 {
+		t.Fatalf("wrong samples. got=%v", values)
+	}
+	for i, v := range expectedValues {
+		if len(values[i]) != 2 || values[i][0] != v[0] || values[i][1] != v[1] {
+			t.Errorf("wrong values of sample %d. expected=%v, got=%v", i, v, values[i])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"inter/evaluator"
 	"inter/lexer"
@@ -12,7 +13,12 @@ import (
 
 func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	sandbox := evaluator.NewSandbox(evaluator.AllCapabilities...)
+	sandbox.Stdout = out
+	env := sandbox.NewEnvironment()
+
+	// Imports are resolved relative to the working directory.
+	loader := sandbox.NewLoader(evaluator.SearchPath()...)
 
 	for {
 		fmt.Fprintf(out, ">> ")
@@ -31,7 +37,13 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaled := evaluator.Eval(program, env)
+		in := evaluator.New(context.Background(), evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth})
+		in.SetLoader(loader)
+		evaled := in.Eval(program, env)
+		if err, ok := evaled.(*object.Error); ok {
+			io.WriteString(out, "Error: "+err.Traceback("")+"\n")
+			continue
+		}
 		if evaled != nil {
 			io.WriteString(out, "Result: "+evaled.Inspect()+"\n")
 		}
@@ -0,0 +1,51 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	messages := []interface{}{
+		map[string]int{"id": 1},
+		[]string{"a", "b"},
+	}
+	for _, msg := range messages {
+		if err := WriteMessage(&buf, msg); err != nil {
+			t.Fatalf("WriteMessage failed: %s", err)
+		}
+	}
+
+	r := bufio.NewReader(&buf)
+	tests := []string{`{"id":1}`, `["a","b"]`}
+	for _, tt := range tests {
+		content, err := ReadMessage(r)
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %s", err)
+		}
+		if string(content) != tt {
+			t.Errorf("wrong content. expected=%q, got=%q", tt, content)
+		}
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Content-Type: x\r\n\r\n{}", "missing Content-Length header"},
+		{"Content-Length: x\r\n\r\n{}", `invalid Content-Length " x"`},
+		{"garbage\r\n\r\n", `malformed header "garbage"`},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadMessage(bufio.NewReader(strings.NewReader(tt.input)))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%v", tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"inter/profile"
+	"os"
+	"path/filepath"
+)
+
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	cpuprofile := flags.String("cpuprofile", "", "write a pprof CPU profile of the script to `file`")
+	seed := flags.Int64("seed", 0, "seed math.random with `n`")
+	root := flags.String("root", "", "restrict the file functions of the script to the files under `dir`")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: inter run [-cpuprofile file] [-root dir] [-seed n] file\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+		}
+		return 1
+	}
+
+	sandbox := evaluator.NewSandbox(evaluator.AllCapabilities...)
+	sandbox.Seed = *seed
+	file := path
+	if *root != "" {
+		sandbox.FS = evaluator.DirFS(*root)
+		// Imports are resolved in the root, relative to the script.
+		if rel, err := filepath.Rel(*root, path); err == nil {
+			file = rel
+		}
+	}
+	env := sandbox.NewEnvironment()
+	loader := newLoader(sandbox)
+	loader.SetFile(env, file)
+
+	in := evaluator.New(context.Background(), evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth})
+	in.SetLoader(loader)
+
+	var profiler *profile.Profiler
+	if *cpuprofile != "" {
+		profiler = profile.New(path, profile.DefaultPeriod)
+		stop := in.Profile(profiler, profile.DefaultPeriod)
+		defer stop()
+	}
+
+	result := in.Eval(program, env)
+
+	if profiler != nil {
+		if err := writeFile(*cpuprofile, profiler.Write); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Traceback(path))
+		return 1
+	}
+	return 0
+}
+
+// newLoader returns the module loader of the commands running scripts in
+// sandbox, searching the directories listed in $INTER_PATH.
+func newLoader(sandbox *evaluator.Sandbox) *evaluator.Loader {
+	return sandbox.NewLoader(evaluator.SearchPath()...)
+}
@@ -0,0 +1,205 @@
+// Package scope resolves identifiers to the let statements, imports and
+// function parameters that bind them.
+//
+// Only programs, function literals and catch blocks introduce scopes: like
+// the evaluator, a let inside an if block binds in the enclosing function.
+// References in a function body are resolved once the enclosing scope is complete, since the
+// body only runs when the function is called.
+package scope
+
+import (
+	"inter/ast"
+)
+
+type Kind int
+
+const (
+	Let Kind = iota
+	Param
+	Catch  // the parameter of a catch block
+	Import // a module bound by an import statement
+)
+
+// Binding is a name introduced by a let statement, an import statement, a
+// function parameter or a catch block.
+type Binding struct {
+	Name  string
+	Kind  Kind
+	Ident *ast.Identifier // the defining identifier, the path of imports without alias
+	Value ast.Expression  // the bound expression or import path, nil for parameters and catch
+	Scope *Scope
+
+	Uses    []*ast.Identifier
+	Shadows *Binding // binding of the same name in an enclosing scope
+}
+
+// Scope is the set of bindings of a program, a function literal or a catch
+// block.
+type Scope struct {
+	Outer    *Scope
+	Node     ast.Node // *ast.Program, *ast.FunctionLiteral or a catch *ast.BlockStatement
+	Bindings []*Binding
+
+	names map[string]*Binding // the latest binding of each name
+}
+
+// Lookup returns the binding name currently refers to in s or its enclosing
+// scopes, or nil.
+func (s *Scope) Lookup(name string) *Binding {
+	for ; s != nil; s = s.Outer {
+		if b, ok := s.names[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+type Info struct {
+	Global     *Scope
+	Scopes     map[ast.Node]*Scope // by *ast.Program and *ast.FunctionLiteral
+	Bindings   []*Binding          // in resolution order
+	Defs       map[*ast.Identifier]*Binding
+	Uses       map[*ast.Identifier]*Binding
+	Unresolved []*ast.Identifier // references to builtins or undefined names
+}
+
+// BindingAt returns the binding that ident defines or refers to, or nil.
+func (info *Info) BindingAt(ident *ast.Identifier) *Binding {
+	if b, ok := info.Defs[ident]; ok {
+		return b
+	}
+	return info.Uses[ident]
+}
+
+type pendingFunction struct {
+	fn    *ast.FunctionLiteral
+	outer *Scope
+}
+
+type resolver struct {
+	info    *Info
+	pending []pendingFunction
+}
+
+func Resolve(program *ast.Program) *Info {
+	r := &resolver{info: &Info{
+		Scopes: make(map[ast.Node]*Scope),
+		Defs:   make(map[*ast.Identifier]*Binding),
+		Uses:   make(map[*ast.Identifier]*Binding),
+	}}
+
+	r.info.Global = r.newScope(nil, program)
+	r.statements(program.Statements, r.info.Global)
+
+	for len(r.pending) > 0 {
+		f := r.pending[0]
+		r.pending = r.pending[1:]
+		r.function(f.fn, f.outer)
+	}
+
+	return r.info
+}
+
+func (r *resolver) newScope(outer *Scope, node ast.Node) *Scope {
+	s := &Scope{Outer: outer, Node: node, names: make(map[string]*Binding)}
+	r.info.Scopes[node] = s
+	return s
+}
+
+func (r *resolver) function(fn *ast.FunctionLiteral, outer *Scope) {
+	s := r.newScope(outer, fn)
+	for i, param := range fn.FunctionParameters {
+		// A default is evaluated before its parameter is bound.
+		if def := fn.Default(i); def != nil {
+			r.expression(def, s)
+		}
+		r.define(s, param, Param, nil)
+	}
+	if fn.Rest != nil {
+		r.define(s, fn.Rest, Param, nil)
+	}
+
+	if fn.FunctionBody != nil {
+		r.statements(fn.FunctionBody.Statements, s)
+	}
+}
+
+func (r *resolver) define(s *Scope, ident *ast.Identifier, kind Kind, value ast.Expression) {
+	b := &Binding{Name: ident.Value, Kind: kind, Ident: ident, Value: value, Scope: s}
+	b.Shadows = s.Outer.Lookup(ident.Value)
+
+	s.names[ident.Value] = b
+	s.Bindings = append(s.Bindings, b)
+	r.info.Bindings = append(r.info.Bindings, b)
+	r.info.Defs[ident] = b
+}
+
+func (r *resolver) use(ident *ast.Identifier, s *Scope) {
+	b := s.Lookup(ident.Value)
+	if b == nil {
+		r.info.Unresolved = append(r.info.Unresolved, ident)
+		return
+	}
+
+	b.Uses = append(b.Uses, ident)
+	r.info.Uses[ident] = b
+}
+
+func (r *resolver) statements(list []ast.Statement, s *Scope) {
+	for _, st := range list {
+		switch st := st.(type) {
+		case *ast.LetStatement:
+			r.expression(st.Value, s)
+			r.define(s, st.Name, Let, st.Value)
+		case *ast.ReturnStatement:
+			r.expression(st.ReturnValue, s)
+		case *ast.ThrowStatement:
+			r.expression(st.Value, s)
+		case *ast.ImportStatement:
+			ident := st.Alias
+			if ident == nil {
+				ident = &ast.Identifier{Token: st.Path.Token, Value: st.Name()}
+			}
+			r.define(s, ident, Import, st.Path)
+		case *ast.ExpressionStatement:
+			r.expression(st.Expression, s)
+		case *ast.BlockStatement:
+			r.statements(st.Statements, s)
+		}
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression, s *Scope) {
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Identifier:
+			r.use(n, s)
+		case *ast.FunctionLiteral:
+			r.pending = append(r.pending, pendingFunction{fn: n, outer: s})
+			return false
+		case *ast.BlockStatement:
+			r.statements(n.Statements, s)
+			return false
+		case *ast.MemberExpression:
+			// The property is a name, not a reference.
+			r.expression(n.Left, s)
+			return false
+		case *ast.NamedArgument:
+			// So is the name of a named argument.
+			r.expression(n.Value, s)
+			return false
+		case *ast.TryExpression:
+			r.statements(n.Body.Statements, s)
+			if n.CatchBody != nil {
+				c := r.newScope(s, n.CatchBody)
+				r.define(c, n.CatchParam, Catch, nil)
+				r.statements(n.CatchBody.Statements, c)
+			}
+			if n.FinallyBody != nil {
+				r.statements(n.FinallyBody.Statements, s)
+			}
+			return false
+		}
+		return true
+	})
+}
@@ -0,0 +1,110 @@
+package scope
+
+import (
+	"inter/ast"
+	"inter/lexer"
+	"inter/parser"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	input := `
+let x = 1;
+let f = fn(a) {
+  let y = a + x;
+  if (y > 0) { let z = y; }
+  z + g(y)
+};
+let g = fn(x) { x };
+let x = x + 1;
+puts(x);
+let h = fn(a, b = a, ...r) { h(b: r) };
+try { 1 } catch (x) { x }; x;
+`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	info := Resolve(program)
+
+	// Every reference is identified by its line and column, and mapped to
+	// the position of the defining identifier, or 0:0 when unresolved.
+	tests := []struct {
+		line, column       int
+		defLine, defColumn int
+	}{
+		{4, 11, 3, 12},   // a
+		{4, 15, 9, 5},    // x, the latest binding since the body runs later
+		{5, 7, 4, 7},     // y
+		{6, 3, 5, 20},    // z, bound inside the if block
+		{6, 7, 8, 5},     // g, declared after f
+		{8, 17, 8, 12},   // x, the parameter
+		{9, 9, 2, 5},     // x, the previous binding
+		{10, 1, 0, 0},    // puts
+		{10, 6, 9, 5},    // x, the latest binding
+		{11, 19, 11, 12}, // a, the parameter before the default
+		{11, 30, 11, 5},  // h
+		{11, 35, 11, 25}, // r, the rest parameter
+		{12, 23, 12, 18}, // x, the catch parameter
+		{12, 28, 9, 5},   // x, the catch parameter is gone after the block
+	}
+
+	for _, ident := range info.Unresolved {
+		if ident.Value != "puts" {
+			t.Errorf("%s at %d:%d should not be a reference", ident.Value, ident.Token.Line, ident.Token.Column)
+		}
+	}
+
+	uses := map[[2]int]*ast.Identifier{}
+	ast.Inspect(program, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Identifier); ok {
+			if _, isDef := info.Defs[ident]; !isDef {
+				uses[[2]int{ident.Token.Line, ident.Token.Column}] = ident
+			}
+		}
+		return true
+	})
+
+	for _, tt := range tests {
+		ident, ok := uses[[2]int{tt.line, tt.column}]
+		if !ok {
+			t.Errorf("no reference at %d:%d", tt.line, tt.column)
+			continue
+		}
+
+		b := info.BindingAt(ident)
+		if tt.defLine == 0 {
+			if b != nil {
+				t.Errorf("%s at %d:%d should be unresolved", ident.Value, tt.line, tt.column)
+			}
+			continue
+		}
+
+		if b == nil {
+			t.Errorf("%s at %d:%d is unresolved", ident.Value, tt.line, tt.column)
+			continue
+		}
+
+		if b.Ident.Token.Line != tt.defLine || b.Ident.Token.Column != tt.defColumn {
+			t.Errorf("%s at %d:%d resolved to %d:%d, want %d:%d", ident.Value, tt.line, tt.column,
+				b.Ident.Token.Line, b.Ident.Token.Column, tt.defLine, tt.defColumn)
+		}
+	}
+}
+
+func TestShadows(t *testing.T) {
+	program := parser.New(lexer.New("let x = 1; let f = fn(x) { let y = x; fn(y) { y } };")).ParseProgram()
+	info := Resolve(program)
+
+	shadows := map[string]int{}
+	for _, b := range info.Bindings {
+		if b.Shadows != nil {
+			shadows[b.Name]++
+		}
+	}
+
+	if shadows["x"] != 1 || shadows["y"] != 1 || len(shadows) != 2 {
+		t.Errorf("wrong shadowed bindings. got=%v", shadows)
+	}
+
+	if len(info.Scopes) != 3 {
+		t.Errorf("wrong number of scopes. got=%d", len(info.Scopes))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"inter/coverage"
+	"inter/evaluator"
+	"inter/object"
+	"inter/tester"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+func testCommand(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	verbose := flags.Bool("v", false, "report every test, not only failures")
+	run := flags.String("run", "", "run only the tests whose name matches `regexp`")
+	junit := flags.String("junit", "", "write a JUnit XML report to `file`")
+	cover := flags.Bool("cover", false, "report statement and branch coverage")
+	coverProfile := flags.String("coverprofile", "", "write an LCOV coverage report to `file`, implies -cover")
+	coverHTML := flags.String("coverhtml", "", "write an HTML coverage report to `file`, implies -cover")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: inter test [flags] [paths...]\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := testFiles(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "no test files")
+		return 1
+	}
+
+	newEnv := func() *object.Environment {
+		return evaluator.NewSandbox(evaluator.AllCapabilities...).NewEnvironment()
+	}
+	runner := &tester.Runner{
+		Verbose:        *verbose,
+		Limits:         evaluator.Limits{MaxDepth: evaluator.DefaultMaxDepth},
+		NewEnvironment: newEnv,
+		Loader:         newLoader(evaluator.NewSandbox(evaluator.AllCapabilities...)),
+	}
+	if *run != "" {
+		filter, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid -run: %s\n", err)
+			return 2
+		}
+		runner.Filter = filter
+	}
+	if *cover || *coverProfile != "" || *coverHTML != "" {
+		runner.Coverage = coverage.New()
+	}
+
+	status := 0
+	suites := []*tester.Suite{}
+	for _, path := range files {
+		s := runner.RunFile(path)
+		if s.Failed() {
+			status = 1
+		}
+		suites = append(suites, s)
+	}
+
+	type report struct {
+		path  string
+		write func(w io.Writer) error
+	}
+	reports := []report{{*junit, func(w io.Writer) error { return tester.WriteJUnit(w, suites) }}}
+	if runner.Coverage != nil {
+		runner.Coverage.WriteSummary(os.Stdout)
+		reports = append(reports,
+			report{*coverProfile, runner.Coverage.WriteLCOV},
+			report{*coverHTML, runner.Coverage.WriteHTML})
+	}
+
+	for _, r := range reports {
+		if r.path == "" {
+			continue
+		}
+		if err := writeFile(r.path, r.write); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return status
+}
+
+// testFiles returns the files given in paths and the *_test.mk files found
+// in the directories given in paths, walking subdirectories.
+func testFiles(paths []string) ([]string, error) {
+	files := []string{}
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(p, "_test.mk") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
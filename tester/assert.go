@@ -0,0 +1,129 @@
+package tester
+
+import (
+	"fmt"
+	"inter/object"
+	"strings"
+)
+
+// KindAssertion is the kind of the errors of failed assertions. Tests
+// failing with any other error are reported as errors rather than failures.
+const KindAssertion = "AssertionError"
+
+// Define adds the assertion builtins to env:
+//
+//	assert(cond, message?)          fails unless cond is truthy
+//	assert_eq(got, want, message?)  fails unless got and want print the same
+func Define(env *object.Environment) {
+	env.Set("assert", &object.Builtin{Fn: assert})
+	env.Set("assert_eq", &object.Builtin{Fn: assertEq})
+}
+
+func assert(args ...object.Object) object.Object {
+	if len(args) < 1 || len(args) > 2 {
+		return argumentError(len(args), "1 or 2")
+	}
+
+	switch args[0] {
+	case object.FALSE, object.NULL:
+		return failure("assertion failed", args[1:])
+	}
+	return object.NULL
+}
+
+func assertEq(args ...object.Object) object.Object {
+	if len(args) < 2 || len(args) > 3 {
+		return argumentError(len(args), "2 or 3")
+	}
+
+	got, want := args[0], args[1]
+	if got.Type() == want.Type() && got.Inspect() == want.Inspect() {
+		return object.NULL
+	}
+
+	msg := "assert_eq failed"
+	if got.Type() != want.Type() {
+		msg += fmt.Sprintf(": got %s, want %s", got.Type(), want.Type())
+	}
+	return failure(msg+"\n"+Diff(got.Inspect(), want.Inspect()), args[2:])
+}
+
+func argumentError(got int, want string) *object.Error {
+	return &object.Error{
+		Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%s", got, want),
+		Kind:    object.KindArgument,
+	}
+}
+
+// failure returns an assertion error, prefixed with the optional message
+// passed to the assertion.
+func failure(msg string, message []object.Object) *object.Error {
+	if len(message) > 0 {
+		msg = message[0].Inspect() + ": " + msg
+	}
+	return &object.Error{Message: msg, Kind: KindAssertion}
+}
+
+// Diff describes how got differs from want. Single lines are printed one
+// above the other with a caret under the first difference, longer texts
+// as a line diff where "-" marks lines only in want and "+" lines only in
+// got.
+func Diff(got, want string) string {
+	gotLines := strings.Split(got, "\n")
+	wantLines := strings.Split(want, "\n")
+
+	if len(gotLines) == 1 && len(wantLines) == 1 {
+		col := 0
+		for col < len(got) && col < len(want) && got[col] == want[col] {
+			col++
+		}
+		return fmt.Sprintf("  got:  %s\n  want: %s\n        %s^", got, want, strings.Repeat(" ", col))
+	}
+
+	var out []string
+	for _, l := range diffLines(gotLines, wantLines) {
+		out = append(out, "  "+l)
+	}
+	return strings.Join(out, "\n")
+}
+
+// diffLines returns the lines of a diff from want to got, computed from
+// their longest common subsequence.
+func diffLines(got, want []string) []string {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// want[i:] and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			switch {
+			case want[i] == got[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			out = append(out, "  "+want[i])
+			i++
+			j++
+		case j == len(got) || (i < len(want) && lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+want[i])
+			i++
+		default:
+			out = append(out, "+ "+got[j])
+			j++
+		}
+	}
+	return out
+}
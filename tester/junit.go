@@ -0,0 +1,113 @@
+package tester
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     string      `xml:"time,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results of suites as JUnit XML, one testsuite per
+// file. Failed assertions are reported as failures, other errors as
+// errors. A file that could not be loaded is reported as a single error
+// case named after the file.
+func WriteJUnit(w io.Writer, suites []*Suite) error {
+	var total time.Duration
+	out := junitSuites{Suites: []junitSuite{}}
+
+	for _, s := range suites {
+		js := junitSuite{Name: s.Path, Time: seconds(s.Duration), Cases: []junitCase{}}
+
+		if s.Err != nil {
+			js.Cases = append(js.Cases, junitCase{
+				Name:      s.Path,
+				ClassName: s.Path,
+				Time:      seconds(s.Duration),
+				Error:     &junitProblem{Message: firstLine(s.Err.Error()), Type: "LoadError", Text: s.Err.Error()},
+			})
+			js.Errors++
+		}
+
+		for _, c := range s.Cases {
+			jc := junitCase{Name: c.Name, ClassName: s.Path, Time: seconds(c.Duration)}
+			if c.Err != nil {
+				problem := &junitProblem{
+					Message: firstLine(c.Err.Message),
+					Type:    c.Err.KindName(),
+					Text:    c.Err.Traceback(s.Path),
+				}
+				if c.Failure() {
+					jc.Failure = problem
+					js.Failures++
+				} else {
+					jc.Error = problem
+					js.Errors++
+				}
+			}
+			js.Cases = append(js.Cases, jc)
+		}
+
+		js.Tests = len(js.Cases)
+		out.Tests += js.Tests
+		out.Failures += js.Failures
+		out.Errors += js.Errors
+		total += s.Duration
+		out.Suites = append(out.Suites, js)
+	}
+	out.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,196 @@
+// Package tester runs tests written in the language. A test file has a
+// name ending in _test.mk, and each of its top-level functions whose name
+// starts with "test" is a test, failing if it returns an error.
+package tester
+
+import (
+	"context"
+	"fmt"
+	"inter/ast"
+	"inter/coverage"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/object"
+	"inter/parser"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Suite is the result of a test file.
+type Suite struct {
+	Path     string
+	Cases    []*Case
+	Err      error // set if the file could not be loaded, no test ran then
+	Duration time.Duration
+}
+
+// Failed reports whether the file could not be loaded or a test failed.
+func (s *Suite) Failed() bool {
+	if s.Err != nil {
+		return true
+	}
+	for _, c := range s.Cases {
+		if c.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Case is the result of a test function.
+type Case struct {
+	Name     string
+	Err      *object.Error // nil if the test passed
+	Duration time.Duration
+}
+
+// Failure reports whether c failed an assertion, as opposed to any other
+// error.
+func (c *Case) Failure() bool {
+	return c.Err != nil && c.Err.Kind == KindAssertion
+}
+
+// Runner runs test files, reporting their progress in the format of go
+// test.
+type Runner struct {
+	Out      io.Writer // the report, os.Stdout if nil
+	Verbose  bool      // report every test, not only failures
+	Filter   *regexp.Regexp
+	Limits   evaluator.Limits
+	Coverage *coverage.Profile // records the coverage of the files, if not nil
+
+	// NewEnvironment returns the environment of a test file, with the
+	// builtins its tests may use. The assertions are added to it.
+	NewEnvironment func() *object.Environment
+
+	// Loader loads the modules imported by the test files, if not nil.
+	// Modules are shared by all the files run, and their coverage is
+	// recorded too.
+	Loader *evaluator.Loader
+}
+
+func (r *Runner) out() io.Writer {
+	if r.Out == nil {
+		return os.Stdout
+	}
+	return r.Out
+}
+
+// RunFile runs the tests of the file at path whose name matches the
+// filter, in source order. The file is evaluated first, and its tests are
+// run in its environment.
+func (r *Runner) RunFile(path string) *Suite {
+	start := time.Now()
+	s := &Suite{Path: path}
+	r.run(s)
+	s.Duration = time.Since(start)
+
+	switch {
+	case s.Err != nil:
+		fmt.Fprintf(r.out(), "%s\nFAIL\t%s\t%.3fs\n", s.Err, path, s.Duration.Seconds())
+	case s.Failed():
+		fmt.Fprintf(r.out(), "FAIL\t%s\t%.3fs\n", path, s.Duration.Seconds())
+	case len(s.Cases) == 0:
+		fmt.Fprintf(r.out(), "ok  \t%s\t%.3fs [no tests to run]\n", path, s.Duration.Seconds())
+	default:
+		fmt.Fprintf(r.out(), "ok  \t%s\t%.3fs\n", path, s.Duration.Seconds())
+	}
+	return s
+}
+
+func (r *Runner) run(s *Suite) {
+	src, err := os.ReadFile(s.Path)
+	if err != nil {
+		s.Err = err
+		return
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		msgs := []string{}
+		for _, err := range errs {
+			msgs = append(msgs, fmt.Sprintf("%s:%s", s.Path, err))
+		}
+		s.Err = fmt.Errorf("%s", strings.Join(msgs, "\n"))
+		return
+	}
+
+	in := evaluator.New(context.Background(), r.Limits)
+	if r.Coverage != nil {
+		r.Coverage.Add(s.Path, string(src), program)
+		in.SetHook(r.Coverage)
+	}
+
+	env := object.NewEnvironment()
+	if r.NewEnvironment != nil {
+		env = r.NewEnvironment()
+	}
+	Define(env)
+
+	if r.Loader != nil {
+		if r.Coverage != nil && r.Loader.Loaded == nil {
+			r.Loader.Loaded = func(path, src string, program *ast.Program) {
+				r.Coverage.Add(path, src, program)
+			}
+		}
+		r.Loader.SetFile(env, s.Path)
+		in.SetLoader(r.Loader)
+	}
+
+	if err, ok := in.Eval(program, env).(*object.Error); ok {
+		s.Err = fmt.Errorf("error: %s", err.Traceback(s.Path))
+		return
+	}
+
+	for _, name := range Tests(program) {
+		if r.Filter != nil && !r.Filter.MatchString(name) {
+			continue
+		}
+		fn, _ := env.Get(name)
+		s.Cases = append(s.Cases, r.runTest(in, s.Path, name, fn))
+	}
+}
+
+func (r *Runner) runTest(in *evaluator.Interpreter, path, name string, fn object.Object) *Case {
+	if r.Verbose {
+		fmt.Fprintf(r.out(), "=== RUN   %s\n", name)
+	}
+
+	start := time.Now()
+	c := &Case{Name: name}
+	if err, ok := in.Call(fn).(*object.Error); ok {
+		c.Err = err
+	}
+	c.Duration = time.Since(start)
+
+	switch {
+	case c.Err != nil:
+		fmt.Fprintf(r.out(), "--- FAIL: %s (%.2fs)\n", name, c.Duration.Seconds())
+		fmt.Fprintf(r.out(), "    %s\n", strings.ReplaceAll(c.Err.Traceback(path), "\n", "\n    "))
+	case r.Verbose:
+		fmt.Fprintf(r.out(), "--- PASS: %s (%.2fs)\n", name, c.Duration.Seconds())
+	}
+	return c
+}
+
+// Tests returns the names of the tests of program: the functions bound by
+// its top-level let statements whose name starts with "test".
+func Tests(program *ast.Program) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, st := range program.Statements {
+		let, ok := st.(*ast.LetStatement)
+		if !ok || !strings.HasPrefix(let.Name.Value, "test") || seen[let.Name.Value] {
+			continue
+		}
+		if _, ok := let.Value.(*ast.FunctionLiteral); ok {
+			names = append(names, let.Name.Value)
+			seen[let.Name.Value] = true
+		}
+	}
+	return names
+}
@@ -0,0 +1,200 @@
+package tester
+
+import (
+	"bytes"
+	"encoding/xml"
+	"inter/coverage"
+	"inter/evaluator"
+	"inter/lexer"
+	"inter/parser"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testFile = `let add = fn(a, b) { a + b };
+let test_add = fn() { assert_eq(add(1, 2), 3) };
+let test_fail = fn() {
+  assert(add(1, 1) == 3, "sum");
+};
+let test_error = fn() { 1 / 0 };
+let helper = fn() { 1 };
+let test_value = 1;
+`
+
+func writeTestFile(t *testing.T, src string) string {
+	path := filepath.Join(t.TempDir(), "add_test.mk")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTests(t *testing.T) {
+	program := parser.New(lexer.New(testFile + "let test_add = fn() { 1 };")).ParseProgram()
+	got := strings.Join(Tests(program), " ")
+	if got != "test_add test_fail test_error" {
+		t.Errorf("wrong tests. got=%q", got)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	path := writeTestFile(t, testFile)
+	var out bytes.Buffer
+	r := &Runner{Out: &out, Verbose: true}
+	s := r.RunFile(path)
+
+	if !s.Failed() || len(s.Cases) != 3 {
+		t.Fatalf("wrong suite. got=%+v", s)
+	}
+	if s.Cases[0].Err != nil || !s.Cases[1].Failure() || s.Cases[2].Failure() {
+		t.Errorf("wrong results. got=%v, %v, %v", s.Cases[0].Err, s.Cases[1].Err, s.Cases[2].Err)
+	}
+
+	expected := []string{
+		"=== RUN   test_add",
+		"--- PASS: test_add",
+		"=== RUN   test_fail",
+		"--- FAIL: test_fail",
+		"    sum: assertion failed",
+		"      at test_fail (" + path + ":4:3)",
+		"=== RUN   test_error",
+		"--- FAIL: test_error",
+		"    division by zero",
+		"      at test_error (" + path + ":6:25)",
+		"FAIL\t" + path,
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("wrong report.\n%s", out.String())
+	}
+	for i, prefix := range expected {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("wrong line %d. expected prefix %q, got=%q", i, prefix, lines[i])
+		}
+	}
+}
+
+func TestRunFileFilter(t *testing.T) {
+	path := writeTestFile(t, testFile)
+	var out bytes.Buffer
+	r := &Runner{Out: &out, Filter: regexp.MustCompile("add")}
+	s := r.RunFile(path)
+
+	if s.Failed() || len(s.Cases) != 1 || s.Cases[0].Name != "test_add" {
+		t.Errorf("wrong suite. got=%+v", s)
+	}
+	if !strings.HasPrefix(out.String(), "ok  \t"+path+"\t") {
+		t.Errorf("wrong report. got=%q", out.String())
+	}
+}
+
+func TestRunFileErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"let x = ;", ":1:9: no prefix parse function for ; found"},
+		{"let test_a = fn() { 1 }; nope", "error: identifier not found: nope"},
+	}
+
+	for _, tt := range tests {
+		path := writeTestFile(t, tt.src)
+		s := (&Runner{Out: &bytes.Buffer{}}).RunFile(path)
+		if s.Err == nil || !strings.Contains(s.Err.Error(), tt.expected) {
+			t.Errorf("wrong error for %q. expected %q, got=%v", tt.src, tt.expected, s.Err)
+		}
+		if len(s.Cases) != 0 {
+			t.Errorf("tests ran for %q", tt.src)
+		}
+	}
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{`let test = fn() { assert(1); assert(true, "ok") };`, ""},
+		{`let test = fn() { assert(if (false) { 1 }) };`, "assertion failed"},
+		{`let test = fn() { assert() };`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`let test = fn() { assert_eq({"a": [1]}, {"a": [1]}) };`, ""},
+		{`let test = fn() { assert_eq(1, "1") };`, "assert_eq failed: got INTEGER, want STRING\n  got:  1\n  want: 1\n         ^"},
+		{`let test = fn() { assert_eq("abc", "abd", "name") };`, "name: assert_eq failed\n  got:  abc\n  want: abd\n          ^"},
+	}
+
+	for _, tt := range tests {
+		s := (&Runner{Out: &bytes.Buffer{}}).RunFile(writeTestFile(t, tt.src))
+		if len(s.Cases) != 1 {
+			t.Fatalf("wrong suite for %q. got=%+v", tt.src, s)
+		}
+
+		got := ""
+		if s.Cases[0].Err != nil {
+			got = s.Cases[0].Err.Message
+		}
+		if got != tt.expected {
+			t.Errorf("wrong result for %q.\nexpected=%q\ngot=     %q", tt.src, tt.expected, got)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := Diff("a\nb\nc\nd", "a\nc\nx\nd")
+	expected := "    a\n  + b\n    c\n  - x\n    d"
+	if got != expected {
+		t.Errorf("wrong diff.\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	path := writeTestFile(t, testFile)
+	s := (&Runner{Out: &bytes.Buffer{}}).RunFile(path)
+
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, []*Suite{s}); err != nil {
+		t.Fatal(err)
+	}
+
+	var report junitSuites
+	if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, out.String())
+	}
+
+	if report.Tests != 3 || report.Failures != 1 || report.Errors != 1 || len(report.Suites) != 1 {
+		t.Fatalf("wrong totals. got=%+v", report)
+	}
+
+	cases := report.Suites[0].Cases
+	if cases[0].Failure != nil || cases[0].Error != nil {
+		t.Errorf("passing test has a problem. got=%+v", cases[0])
+	}
+	if cases[1].Failure == nil || cases[1].Failure.Type != KindAssertion || cases[1].Failure.Message != "sum: assertion failed" {
+		t.Errorf("wrong failure. got=%+v", cases[1].Failure)
+	}
+	if cases[2].Error == nil || cases[2].Error.Type != "ZeroDivisionError" {
+		t.Errorf("wrong error. got=%+v", cases[2].Error)
+	}
+}
+
+func TestRunFileImports(t *testing.T) {
+	path := writeTestFile(t, `import "./lib"; let test_lib = fn() { assert_eq(lib.double(2), 4) };`)
+	lib := filepath.Join(filepath.Dir(path), "lib.mk")
+	if err := os.WriteFile(lib, []byte("let double = fn(x) { x * 2 };\nlet unused = fn() { 1 };"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Runner{Out: &bytes.Buffer{}, Loader: evaluator.NewLoader(nil), Coverage: coverage.New()}
+	if s := r.RunFile(path); s.Failed() {
+		t.Fatalf("test failed: %v %+v", s.Err, s.Cases)
+	}
+
+	if len(r.Coverage.Files) != 2 || r.Coverage.Files[1].Name != lib {
+		t.Fatalf("module coverage not recorded. got=%+v", r.Coverage.Files)
+	}
+	if statements, hit, _, _ := r.Coverage.Files[1].Counts(); statements != 4 || hit != 3 {
+		t.Errorf("wrong module coverage. got=%d/%d", hit, statements)
+	}
+}
@@ -0,0 +1,248 @@
+// Package vet reports suspicious constructs in programs, such as unused
+// bindings or code that can never run.
+package vet
+
+import (
+	"fmt"
+	"inter/ast"
+	"inter/evaluator"
+	"inter/object"
+	"inter/scope"
+	"sort"
+	"strings"
+)
+
+const (
+	Unused      = "unused"
+	Shadow      = "shadow"
+	Unreachable = "unreachable"
+	SelfCompare = "selfcompare"
+	ConstantIf  = "constantif"
+	Arity       = "arity"
+)
+
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Category string `json:"category"`
+	Message  string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s", d.Line, d.Column, d.Message)
+}
+
+type checker struct {
+	info        *scope.Info
+	diagnostics []Diagnostic
+}
+
+// Check returns the diagnostics for program, sorted by position. Top-level
+// bindings are never reported as unused since the host may read them.
+func Check(program *ast.Program) []Diagnostic {
+	c := &checker{info: scope.Resolve(program)}
+
+	c.checkBindings()
+	ast.Inspect(program, c.checkNode)
+
+	sort.SliceStable(c.diagnostics, func(i, j int) bool {
+		a, b := c.diagnostics[i], c.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return c.diagnostics
+}
+
+func (c *checker) report(line, column int, category string, format string, args ...interface{}) {
+	c.diagnostics = append(c.diagnostics, Diagnostic{
+		Line:     line,
+		Column:   column,
+		Category: category,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *checker) checkBindings() {
+	for _, b := range c.info.Bindings {
+		tok := b.Ident.Token
+
+		if len(b.Uses) == 0 && !strings.HasPrefix(b.Name, "_") {
+			switch {
+			case b.Kind == scope.Catch:
+				// Catching an error without looking at it is common.
+			case b.Kind == scope.Param:
+				c.report(tok.Line, tok.Column, Unused, "parameter %s is not used", b.Name)
+			case b.Kind == scope.Import:
+				// Unlike let bindings, imports are never exported.
+				c.report(tok.Line, tok.Column, Unused, "%s imported and not used", b.Name)
+			case b.Scope != c.info.Global:
+				c.report(tok.Line, tok.Column, Unused, "%s declared and not used", b.Name)
+			}
+		}
+
+		if b.Shadows != nil {
+			outer := b.Shadows.Ident.Token
+			c.report(tok.Line, tok.Column, Shadow, "%s shadows declaration at %d:%d", b.Name, outer.Line, outer.Column)
+		}
+	}
+}
+
+func (c *checker) checkNode(node ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.BlockStatement:
+		c.checkUnreachable(n)
+	case *ast.InfixExpression:
+		c.checkSelfComparison(n)
+	case *ast.IfExpression:
+		c.checkConstantCondition(n)
+	case *ast.CallExpression:
+		c.checkArity(n)
+	}
+	return true
+}
+
+func (c *checker) checkUnreachable(bs *ast.BlockStatement) {
+	for i, st := range bs.Statements {
+		if terminates(st) && i+1 < len(bs.Statements) {
+			tok := ast.StartToken(bs.Statements[i+1])
+			c.report(tok.Line, tok.Column, Unreachable, "unreachable code")
+			return
+		}
+	}
+}
+
+// terminates reports whether the statements following st never run.
+func terminates(st ast.Statement) bool {
+	switch st.(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement:
+		return true
+	}
+	return false
+}
+
+// checkSelfComparison reports comparisons of an expression with itself.
+// Their result is known, except that x == x is false and x != x true when
+// x is a NaN float, so those are only reported as suspicious.
+func (c *checker) checkSelfComparison(exp *ast.InfixExpression) {
+	switch exp.Operator {
+	case "==", "!=", "<", ">":
+	default:
+		return
+	}
+
+	if !isPure(exp.Left) || exp.Left.String() != exp.Right.String() {
+		return
+	}
+
+	if exp.Operator == "<" || exp.Operator == ">" {
+		c.report(exp.Token.Line, exp.Token.Column, SelfCompare,
+			"comparison of %s with itself is always false", exp.Left.String())
+		return
+	}
+	c.report(exp.Token.Line, exp.Token.Column, SelfCompare,
+		"suspicious comparison of %s with itself", exp.Left.String())
+}
+
+func (c *checker) checkConstantCondition(exp *ast.IfExpression) {
+	if !isConstant(exp.Condition) {
+		return
+	}
+
+	cond := evaluator.Eval(exp.Condition, object.NewEnvironment())
+	if cond == nil || cond.Type() == object.ERROR_OBJ {
+		return
+	}
+
+	always := !(cond == object.FALSE || cond == object.NULL)
+	c.report(exp.Token.Line, exp.Token.Column, ConstantIf, "condition is always %t", always)
+}
+
+func (c *checker) checkArity(call *ast.CallExpression) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return
+	}
+
+	b := c.info.Uses[ident]
+	if b == nil || b.Kind != scope.Let {
+		return
+	}
+
+	fn, ok := b.Value.(*ast.FunctionLiteral)
+	if !ok {
+		return
+	}
+
+	positional, named := 0, 0
+	for _, arg := range call.Arguments {
+		switch arg := arg.(type) {
+		case *ast.SpreadExpression:
+			// The number of arguments is only known at run time.
+			return
+		case *ast.NamedArgument:
+			if !hasParameter(fn, arg.Name.Value) {
+				c.report(arg.Name.Token.Line, arg.Name.Token.Column, Arity,
+					"unknown argument %s in call to %s", arg.Name.Value, ident.Value)
+			}
+			named++
+		default:
+			positional++
+		}
+	}
+
+	required, n := 0, len(fn.FunctionParameters)
+	for i := range fn.FunctionParameters {
+		if fn.Default(i) == nil {
+			required++
+		}
+	}
+	if positional > n && fn.Rest == nil || positional+named < required {
+		want := fmt.Sprint(required)
+		switch {
+		case fn.Rest != nil:
+			want = "at least " + want
+		case required < n:
+			want = fmt.Sprintf("%d to %d", required, n)
+		}
+		c.report(ident.Token.Line, ident.Token.Column, Arity,
+			"wrong number of arguments in call to %s: got %d, want %s",
+			ident.Value, positional+named, want)
+	}
+}
+
+func hasParameter(fn *ast.FunctionLiteral, name string) bool {
+	for _, param := range fn.FunctionParameters {
+		if param.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// isPure reports whether evaluating exp twice gives the same value.
+func isPure(exp ast.Expression) bool {
+	pure := true
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.CallExpression, *ast.FunctionLiteral:
+			pure = false
+		}
+		return pure
+	})
+	return pure
+}
+
+// isConstant reports whether exp only depends on literals.
+func isConstant(exp ast.Expression) bool {
+	constant := true
+	ast.Inspect(exp, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Identifier, *ast.CallExpression, *ast.FunctionLiteral, *ast.IfExpression:
+			constant = false
+		}
+		return constant
+	})
+	return constant
+}
@@ -0,0 +1,76 @@
+package vet
+
+import (
+	"inter/lexer"
+	"inter/parser"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; let f = fn(a) { a + x }; f(x);", []string{}},
+		{"let f = fn(a, b) { a }; f(1, 2);", []string{"1:15: parameter b is not used"}},
+		{"let f = fn(_a) { 1 }; f(1);", []string{}},
+		{"let f = fn() { let y = 1; 2 }; f();", []string{"1:20: y declared and not used"}},
+		{"let unused = 1;", []string{}},
+		{"let x = 1; let f = fn(x) { x }; f(x);", []string{"1:23: x shadows declaration at 1:5"}},
+		{
+			"let f = fn(a) {\n  return a;\n  a + 1;\n};\nf(1);",
+			[]string{"3:3: unreachable code"},
+		},
+		{
+			"let f = fn(a) {\n  throw a;\n  a + 1;\n};\nf(1);",
+			[]string{"3:3: unreachable code"},
+		},
+		{"let f = fn() { try { 1 } catch (e) { 2 } }; f();", []string{}},
+		{`import "a"; import b from "./b"; import "c"; c.f();`, []string{
+			"1:8: a imported and not used",
+			"1:20: b imported and not used",
+		}},
+		{"let x = 1; x == x; x < x; x == 1; x != x;", []string{
+			"1:14: suspicious comparison of x with itself",
+			"1:22: comparison of x with itself is always false",
+			"1:37: suspicious comparison of x with itself",
+		}},
+		{"let f = fn() { 1 }; f() == f();", []string{}},
+		{"if (1 < 2) { 1 }; if (false) { 2 }; let x = 1; if (x) { 3 }", []string{
+			"1:1: condition is always true",
+			"1:19: condition is always false",
+		}},
+		{"let f = fn(a, b) { a + b }; f(1); f(1, 2); f(1, 2, 3);", []string{
+			"1:29: wrong number of arguments in call to f: got 1, want 2",
+			"1:44: wrong number of arguments in call to f: got 3, want 2",
+		}},
+		{"let f = fn(a, b = 1) { a + b }; f(); f(1); f(1, b: 2); f(1, 2, 3);", []string{
+			"1:33: wrong number of arguments in call to f: got 0, want 1 to 2",
+			"1:56: wrong number of arguments in call to f: got 3, want 1 to 2",
+		}},
+		{"let f = fn(a, ...xs) { [a, xs] }; f(); f(1, 2, 3); f(...[]); f(1, c: 2);", []string{
+			"1:35: wrong number of arguments in call to f: got 0, want at least 1",
+			"1:67: unknown argument c in call to f",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) > 0 {
+			t.Fatalf("parse errors for %q: %v", tt.input, p.Errors())
+		}
+
+		diagnostics := Check(program)
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("wrong number of diagnostics for %q. expected=%q, got=%v", tt.input, tt.expected, diagnostics)
+			continue
+		}
+
+		for i, d := range diagnostics {
+			if d.String() != tt.expected[i] {
+				t.Errorf("wrong diagnostic for %q. expected=%q, got=%q", tt.input, tt.expected[i], d.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"inter/lexer"
+	"inter/parser"
+	"inter/vet"
+	"os"
+)
+
+type fileDiagnostic struct {
+	File string `json:"file"`
+	vet.Diagnostic
+}
+
+func vetCommand(args []string) int {
+	flags := flag.NewFlagSet("vet", flag.ExitOnError)
+	jsonOutput := flags.Bool("json", false, "print diagnostics as JSON")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: inter vet [-json] files...\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	diagnostics := []fileDiagnostic{}
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+
+		p := parser.New(lexer.New(string(src)))
+		program := p.ParseProgram()
+		if errs := p.ErrorList(); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", path, err)
+			}
+			status = 1
+			continue
+		}
+
+		for _, d := range vet.Check(program) {
+			diagnostics = append(diagnostics, fileDiagnostic{File: path, Diagnostic: d})
+		}
+	}
+
+	if len(diagnostics) > 0 {
+		status = 1
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(diagnostics)
+		return status
+	}
+
+	for _, d := range diagnostics {
+		fmt.Fprintf(os.Stderr, "%s:%s\n", d.File, d.Diagnostic)
+	}
+	return status
+}